@@ -39,9 +39,12 @@ type MyStatefulSetSpec struct {
 	// VolumeClaimTemplates 是用于创建 PVC 的模板
 	VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
 
-	// ServiceName 是用于管理 Pod 的服务名称
+	// ServiceName 是用于管理 Pod 的服务名称，Pod 的 subdomain 会被设置为该值
 	ServiceName string `json:"serviceName"`
 
+	// ManageService 为 true 时由控制器根据 Pod 模板中的端口创建并管理名为 ServiceName 的 Headless Service
+	ManageService bool `json:"manageService,omitempty"`
+
 	// PodManagementPolicy 控制 Pod 的管理策略
 	PodManagementPolicy appsv1.PodManagementPolicyType `json:"podManagementPolicy,omitempty"`
 
@@ -50,6 +53,24 @@ type MyStatefulSetSpec struct {
 
 	// RevisionHistoryLimit 是保留的历史修订版本的数量
 	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// RollbackTo 指定要回滚到的历史修订版本，控制器恢复模板后会清空该字段
+	RollbackTo *RollbackConfig `json:"rollbackTo,omitempty"`
+
+	// Ordinals 控制 Pod 序号的起始值，Pod 和 PVC 使用 [start, start+replicas) 范围内的序号
+	Ordinals *appsv1.StatefulSetOrdinals `json:"ordinals,omitempty"`
+
+	// PersistentVolumeClaimRetentionPolicy 控制删除 MyStatefulSet 或缩容时是否删除 PVC，未设置时均为 Retain
+	PersistentVolumeClaimRetentionPolicy *appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy `json:"persistentVolumeClaimRetentionPolicy,omitempty"`
+}
+
+// RollbackConfig 描述要回滚到的历史修订版本
+type RollbackConfig struct {
+	// Revision 是要回滚到的修订号，为 0 时回滚到上一个修订版本
+	Revision int64 `json:"revision,omitempty"`
+
+	// RevisionName 是要回滚到的 ControllerRevision 名称，设置时优先于 Revision
+	RevisionName string `json:"revisionName,omitempty"`
 }
 
 // MyStatefulSetStatus defines the observed state of MyStatefulSet.
@@ -77,6 +98,38 @@ type MyStatefulSetStatus struct {
 
 	// CollisionCount 是检测到的版本冲突次数
 	CollisionCount *int32 `json:"collisionCount,omitempty"`
+
+	// RollingUpdate 记录正在进行的滚动更新进度，滚动更新完成后为空
+	RollingUpdate *RollingUpdateStatus `json:"rollingUpdate,omitempty"`
+
+	// Conditions 是 MyStatefulSet 当前状态的标准条件
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MyStatefulSet 的条件类型
+const (
+	// ConditionAvailable 表示就绪副本数已达到期望副本数
+	ConditionAvailable = "Available"
+
+	// ConditionProgressing 表示正在扩缩容、滚动更新或等待 Pod 就绪
+	ConditionProgressing = "Progressing"
+
+	// ConditionDegraded 表示存在反复崩溃的 Pod 或创建 Pod 失败
+	ConditionDegraded = "Degraded"
+
+	// ConditionReconcileError 表示最近一次调谐失败，Message 中记录错误信息
+	ConditionReconcileError = "ReconcileError"
+)
+
+// RollingUpdateStatus 记录滚动更新的进度
+type RollingUpdateStatus struct {
+	// Revision 是本次滚动更新的目标修订版本
+	Revision string `json:"revision"`
+
+	// Ordinals 是已被删除、正在以目标版本重建或等待就绪的 Pod 序号
+	Ordinals []int32 `json:"ordinals,omitempty"`
 }
 
 // +kubebuilder:object:root=true
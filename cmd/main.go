@@ -59,6 +59,9 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var debugTemplateDiff bool
+	var identityLabelPrefix string
+	var requireDeletionConfirmation bool
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -70,6 +73,12 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&debugTemplateDiff, "debug-template-diff", false,
+		"If set, the controller logs the pod template fields that caused each pod to be updated.")
+	flag.StringVar(&identityLabelPrefix, "identity-label-prefix", "apps.my.com",
+		"The prefix of the <prefix>/pod-name and <prefix>/pod-index labels set on pods and PVCs.")
+	flag.BoolVar(&requireDeletionConfirmation, "require-deletion-confirmation", false,
+		"If set, deleting any MyStatefulSet requires the apps.my.com/confirm-deletion annotation set to its name.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -144,15 +153,18 @@ func main() {
 	}
 
 	if err = (&controller.MyStatefulSetReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		DebugTemplateDiff:   debugTemplateDiff,
+		Recorder:            mgr.GetEventRecorderFor("mystatefulset-controller"),
+		IdentityLabelPrefix: identityLabelPrefix,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MyStatefulSet")
 		os.Exit(1)
 	}
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = webhookappsv1.SetupMyStatefulSetWebhookWithManager(mgr); err != nil {
+		if err = webhookappsv1.SetupMyStatefulSetWebhookWithManager(mgr, requireDeletionConfirmation); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "MyStatefulSet")
 			os.Exit(1)
 		}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// podSelector 返回 MyStatefulSet 选择 Pod 所用的标签选择器，
+// 未设置或为空的 Spec.Selector 回退为 mystatefulset-name 标签，避免误选命名空间内的全部 Pod
+func podSelector(myStatefulSet *appsv1.MyStatefulSet) (labels.Selector, error) {
+	selector := myStatefulSet.Spec.Selector
+	if selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0) {
+		return labels.SelectorFromSet(labels.Set{"mystatefulset-name": myStatefulSet.Name}), nil
+	}
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// claimPods 按照 ControllerRefManager 的规则认领 Pod：
+// 受当前 MyStatefulSet 控制且标签仍匹配选择器的 Pod 保留；标签不再匹配的 Pod 解除属主关系；
+// 没有控制者、标签匹配且名称符合 <name>-<ordinal> 的 Pod 被收养；受其他控制者管理的 Pod 一律忽略。
+// MyStatefulSet 正在删除时不再收养新的 Pod。
+func (r *MyStatefulSetReconciler) claimPods(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, pods []corev1.Pod) ([]corev1.Pod, error) {
+	logger := log.FromContext(ctx)
+
+	selector, err := podSelector(myStatefulSet)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]corev1.Pod, 0, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		matches := selector.Matches(labels.Set(pod.Labels))
+		controllerRef := metav1.GetControllerOf(pod)
+
+		if controllerRef != nil {
+			if controllerRef.UID != myStatefulSet.UID {
+				continue
+			}
+			if matches {
+				claimed = append(claimed, *pod)
+				continue
+			}
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			logger.Info("Pod 标签不再匹配选择器，解除属主关系", "pod", pod.Name)
+			removeOwnerReference(pod, myStatefulSet)
+			if err := r.Update(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			continue
+		}
+
+		if !matches || pod.DeletionTimestamp != nil || myStatefulSet.DeletionTimestamp != nil {
+			continue
+		}
+		if _, ok := getOrdinal(myStatefulSet.Name, pod.Name); !ok {
+			continue
+		}
+		logger.Info("收养孤儿 Pod", "pod", pod.Name)
+		pod.OwnerReferences = append(pod.OwnerReferences, *metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")))
+		pod.Labels = createLabels(pod.Labels, myStatefulSet.Name)
+		if err := r.Update(ctx, pod); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		claimed = append(claimed, *pod)
+	}
+	return claimed, nil
+}
+
+// listOwnedPods 列出受当前 MyStatefulSet 控制且标签匹配选择器的 Pod，不写回任何对象。
+// 认领（收养和释放）只在 syncPods 中执行一次，状态计算和历史清理使用该只读列表，
+// 避免同一次调谐中基于可能过期的缓存重复更新 Pod 而产生冲突。
+func (r *MyStatefulSetReconciler) listOwnedPods(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) (*corev1.PodList, error) {
+	selector, err := podSelector(myStatefulSet)
+	if err != nil {
+		return nil, err
+	}
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(req.Namespace)); err != nil {
+		return nil, err
+	}
+	owned := make([]corev1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !metav1.IsControlledBy(pod, myStatefulSet) || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		owned = append(owned, *pod)
+	}
+	podList.Items = owned
+	return podList, nil
+}
+
+// adoptOrphanPVCs 收养名称符合 <模板名>-<name>-<ordinal> 且没有控制者的 PVC，
+// 为其补上 mystatefulset-name 标签，属主引用随后由 syncPVCRetention 按保留策略设置
+func (r *MyStatefulSetReconciler) adoptOrphanPVCs(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) error {
+	if len(myStatefulSet.Spec.VolumeClaimTemplates) == 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, pvcList, client.InNamespace(req.Namespace)); err != nil {
+		return err
+	}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if _, ok := pvc.Labels["mystatefulset-name"]; ok {
+			continue
+		}
+		if pvc.DeletionTimestamp != nil || metav1.GetControllerOf(pvc) != nil {
+			continue
+		}
+		if _, ok := pvcPodName(myStatefulSet, pvc.Name); !ok {
+			continue
+		}
+		logger.Info("收养孤儿 PVC", "pvc", pvc.Name)
+		pvc.Labels = createLabels(pvc.Labels, myStatefulSet.Name)
+		if err := r.Update(ctx, pvc); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// 条件的 Reason
+const (
+	reasonMinimumReplicasAvailable = "MinimumReplicasAvailable"
+	reasonReplicasNotReady         = "ReplicasNotReady"
+	reasonRollingUpdate            = "RollingUpdate"
+	reasonScalingUp                = "ScalingUp"
+	reasonScalingDown              = "ScalingDown"
+	reasonWaitingForReady          = "WaitingForReady"
+	reasonStable                   = "Stable"
+	reasonPodCrashLooping          = "PodCrashLooping"
+	reasonPodCreateFailed          = "PodCreateFailed"
+	reasonHealthy                  = "Healthy"
+	reasonReconcileFailed          = "ReconcileFailed"
+	reasonReconcileSucceeded       = "ReconcileSucceeded"
+)
+
+// errPodCreate 标记创建 Pod 或 PVC 失败的错误，用于设置 Degraded 条件
+var errPodCreate = errors.New("创建 Pod 失败")
+
+// setConditions 根据观察到的 Pod 和本次调谐的结果更新 status 中的条件
+func setConditions(myStatefulSet *appsv1.MyStatefulSet, status *appsv1.MyStatefulSetStatus, podList *corev1.PodList, reconcileErr error) {
+	desiredReplicas := *myStatefulSet.Spec.Replicas
+	generation := myStatefulSet.Generation
+
+	available := metav1.Condition{
+		Type:               appsv1.ConditionAvailable,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reasonMinimumReplicasAvailable,
+		Message:            fmt.Sprintf("%d/%d 个副本就绪", status.ReadyReplicas, desiredReplicas),
+	}
+	if status.ReadyReplicas < desiredReplicas {
+		available.Status = metav1.ConditionFalse
+		available.Reason = reasonReplicasNotReady
+	}
+	meta.SetStatusCondition(&status.Conditions, available)
+
+	progressing := metav1.Condition{
+		Type:               appsv1.ConditionProgressing,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case status.CurrentRevision != status.UpdateRevision:
+		progressing.Reason = reasonRollingUpdate
+		progressing.Message = fmt.Sprintf("正在滚动更新到修订版本 %s，已更新 %d/%d 个副本", status.UpdateRevision, status.UpdatedReplicas, desiredReplicas)
+	case status.Replicas < desiredReplicas:
+		progressing.Reason = reasonScalingUp
+		progressing.Message = fmt.Sprintf("正在扩容，当前 %d 个副本，期望 %d 个", status.Replicas, desiredReplicas)
+	case status.Replicas > desiredReplicas:
+		progressing.Reason = reasonScalingDown
+		progressing.Message = fmt.Sprintf("正在缩容，当前 %d 个副本，期望 %d 个", status.Replicas, desiredReplicas)
+	case status.ReadyReplicas < desiredReplicas:
+		progressing.Reason = reasonWaitingForReady
+		progressing.Message = fmt.Sprintf("等待 Pod 就绪，%d/%d 个副本就绪", status.ReadyReplicas, desiredReplicas)
+	default:
+		progressing.Status = metav1.ConditionFalse
+		progressing.Reason = reasonStable
+		progressing.Message = "所有副本均已更新并就绪"
+	}
+	meta.SetStatusCondition(&status.Conditions, progressing)
+
+	degraded := metav1.Condition{
+		Type:               appsv1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             reasonHealthy,
+		Message:            "没有反复崩溃或创建失败的 Pod",
+	}
+	if crashLooping := crashLoopingPods(podList); len(crashLooping) > 0 {
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = reasonPodCrashLooping
+		degraded.Message = fmt.Sprintf("Pod 反复崩溃: %s", strings.Join(crashLooping, ", "))
+	} else if errors.Is(reconcileErr, errPodCreate) {
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = reasonPodCreateFailed
+		degraded.Message = reconcileErr.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, degraded)
+
+	setReconcileErrorCondition(myStatefulSet, status, reconcileErr)
+}
+
+// setReconcileErrorCondition 在 ReconcileError 条件中记录最近一次调谐的错误
+func setReconcileErrorCondition(myStatefulSet *appsv1.MyStatefulSet, status *appsv1.MyStatefulSetStatus, reconcileErr error) {
+	condition := metav1.Condition{
+		Type:               appsv1.ConditionReconcileError,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: myStatefulSet.Generation,
+		Reason:             reasonReconcileSucceeded,
+		Message:            "调谐成功",
+	}
+	if reconcileErr != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonReconcileFailed
+		condition.Message = reconcileErr.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// recordReconcileError 在无法进入状态计算的早期失败时，仅更新 ReconcileError 条件。
+// 写回状态失败只记录日志，调用方仍返回原始错误。
+func (r *MyStatefulSetReconciler) recordReconcileError(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, reconcileErr error) {
+	status := myStatefulSet.Status.DeepCopy()
+	setReconcileErrorCondition(myStatefulSet, status, reconcileErr)
+	myStatefulSet.Status = *status
+	if err := r.Status().Update(ctx, myStatefulSet); err != nil {
+		log.FromContext(ctx).Error(err, "无法记录 ReconcileError 条件")
+	}
+}
+
+// crashLoopingPods 返回存在处于 CrashLoopBackOff 状态容器的 Pod 名称
+func crashLoopingPods(podList *corev1.PodList) []string {
+	var names []string
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, containerStatus := range statuses {
+			if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == "CrashLoopBackOff" {
+				names = append(names, pod.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
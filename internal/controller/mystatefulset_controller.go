@@ -20,24 +20,74 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+	k8sappsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	appsv1 "my.com/devops-golang-test/api/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// requeueInterval 是等待 Pod 状态变化时重新入队的间隔
+	requeueInterval = 2 * time.Second
+
+	// maxConcurrentPodOperations 是 Parallel 策略下同时操作 Pod 的最大并发数
+	maxConcurrentPodOperations = 10
+
+	// myStatefulSetFinalizer 保证 MyStatefulSet 被删除前完成 Pod 和 PVC 的清理
+	myStatefulSetFinalizer = "apps.my.com/mystatefulset-cleanup"
+
+	// deletionPolicyAnnotation 指定删除 MyStatefulSet 时如何处理 Pod 和 PVC，取值为 Cascade（默认）或 Orphan
+	deletionPolicyAnnotation = "apps.my.com/deletion-policy"
+
+	// deletionPolicyOrphan 表示删除 MyStatefulSet 时保留 Pod 和 PVC
+	deletionPolicyOrphan = "Orphan"
+
+	// defaultIdentityLabelPrefix 是未配置 IdentityLabelPrefix 时序号标识标签使用的前缀
+	defaultIdentityLabelPrefix = "apps.my.com"
 )
 
 // MyStatefulSetReconciler reconciles a MyStatefulSet object
 type MyStatefulSetReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// DebugTemplateDiff 为 true 时，在更新 Pod 前记录导致更新的模板字段差异
+	DebugTemplateDiff bool
+
+	// Recorder 用于在 MyStatefulSet 上记录 Pod、PVC 的创建删除以及滚动更新、扩缩容等事件
+	Recorder record.EventRecorder
+
+	// IdentityLabelPrefix 是 Pod 和 PVC 上序号标识标签（<prefix>/pod-name、<prefix>/pod-index）的前缀，为空时使用 apps.my.com
+	IdentityLabelPrefix string
 }
 
+// +kubebuilder:rbac:groups=apps.my.com,resources=mystatefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps.my.com,resources=mystatefulsets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apps.my.com,resources=mystatefulsets/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
 func (r *MyStatefulSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
@@ -52,33 +102,131 @@ func (r *MyStatefulSetReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	// 处理 MyStatefulSet 的删除逻辑
-	if myStatefulSet.DeletionTimestamp != nil || myStatefulSet.Finalizers != nil {
+	if myStatefulSet.DeletionTimestamp != nil {
+		if !controllerutil.ContainsFinalizer(myStatefulSet, myStatefulSetFinalizer) {
+			return ctrl.Result{}, nil
+		}
 		return r.cleanupMyStatefulSet(ctx, myStatefulSet, req)
 	}
 
+	// 注册 finalizer，确保删除时能够执行清理
+	if controllerutil.AddFinalizer(myStatefulSet, myStatefulSetFinalizer) {
+		if err := r.Update(ctx, myStatefulSet); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// 处理回滚请求，恢复模板后重新入队以触发滚动更新
+	if myStatefulSet.Spec.RollbackTo != nil {
+		if err := r.rollback(ctx, req, myStatefulSet); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	// 获取期望的副本数量
 	if myStatefulSet.Spec.Replicas == nil {
 		err := errors.New("配置缺少期望的副本数量")
 		logger.Error(err, "MyStatefulSet 配置错误")
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonInvalidSpec, "%s", err.Error())
+		r.recordReconcileError(ctx, myStatefulSet, err)
 		return ctrl.Result{}, err
 	}
 	desiredReplicas := *myStatefulSet.Spec.Replicas
 
+	// 选择器无效时无法认领 Pod，同步无法进行
+	if _, err := podSelector(myStatefulSet); err != nil {
+		err = fmt.Errorf("无效的选择器: %w", err)
+		logger.Error(err, "MyStatefulSet 配置错误")
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonInvalidSpec, "%s", err.Error())
+		r.recordReconcileError(ctx, myStatefulSet, err)
+		return ctrl.Result{}, err
+	}
+
+	// 记录 Pod 模板的修订版本
+	revisions, err := r.syncRevisions(ctx, req, myStatefulSet)
+	if err != nil {
+		r.recordReconcileError(ctx, myStatefulSet, err)
+		return ctrl.Result{}, err
+	}
+
+	// 同步 Headless Service，失败时记录事件并继续同步 Pod，错误在本次调谐结束时返回以触发重试
+	serviceErr := r.syncService(ctx, req, myStatefulSet)
+	if serviceErr != nil {
+		logger.Error(serviceErr, "同步 Headless Service 失败")
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonFailedSyncService, "同步 Headless Service %s 失败: %v", myStatefulSet.Spec.ServiceName, serviceErr)
+	}
+
+	// 同步 Pod 和 PVC
+	result, syncErr := r.syncPods(ctx, req, myStatefulSet, desiredReplicas, revisions)
+	syncErr = errors.Join(serviceErr, syncErr)
+
+	// 清理超出保留数量的历史修订版本
+	if err := r.truncateHistory(ctx, req, myStatefulSet, revisions); err != nil {
+		syncErr = errors.Join(syncErr, err)
+	}
+
+	// 每次调谐都根据观察到的 Pod 刷新状态，只有本代配置被完整处理后才推进 ObservedGeneration
+	fullyProcessed := syncErr == nil && result.IsZero()
+	if err := r.updateStatus(ctx, req, myStatefulSet, revisions, fullyProcessed, syncErr); err != nil {
+		return ctrl.Result{}, errors.Join(syncErr, err)
+	}
+
+	return result, syncErr
+}
+
+// syncPods 依次创建缺失的 Pod、删除多余的 Pod 并滚动更新过期的 Pod
+func (r *MyStatefulSetReconciler) syncPods(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, desiredReplicas int32, revisions *revisionState) (ctrl.Result, error) {
 	// 列出与 MyStatefulSet 关联的 Pod
 	podList, err := r.listPods(ctx, req, myStatefulSet)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// 为旧版控制器创建的 Pod 补充模板哈希标签，避免升级后全部重建
+	if err := r.stampLegacyPods(ctx, myStatefulSet, podList, revisions); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 收养名称匹配的孤儿 PVC
+	if err := r.adoptOrphanPVCs(ctx, req, myStatefulSet); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 按保留策略调整 PVC 的属主引用，并清理缩容遗留的 PVC
+	if err := r.syncPVCRetention(ctx, req, myStatefulSet, podList, desiredReplicas); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// 创建缺失的 Pod 和 PVC
-	if err := r.createMissingPodsAndPVCs(ctx, req, myStatefulSet, podList, desiredReplicas); err != nil {
+	waiting, err := r.createMissingPodsAndPVCs(ctx, req, myStatefulSet, podList, desiredReplicas, revisions)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	if waiting {
+		// 前一个序号的 Pod 尚未就绪，稍后重新入队而不是阻塞当前协程
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
+
+	// 删除序号超出期望副本数的 Pod
+	scalingDown, err := r.scaleDown(ctx, myStatefulSet, podList, desiredReplicas)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if scalingDown {
+		// 缩容尚未完成，等待 Pod 终止后再继续
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
 
 	// 更新需要更新的 Pod
-	if err := r.updatePods(ctx, myStatefulSet, podList, req); err != nil {
+	updating, err := r.updatePods(ctx, myStatefulSet, podList)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	if updating {
+		// 仍有 Pod 等待更新，稍后继续滚动更新
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -89,178 +237,700 @@ func (r *MyStatefulSetReconciler) getMyStatefulSet(ctx context.Context, req ctrl
 	return &myStatefulSet, err
 }
 
+// listPods 列出命名空间内的 Pod 并按 Spec.Selector 认领属于 MyStatefulSet 的 Pod
 func (r *MyStatefulSetReconciler) listPods(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) (*corev1.PodList, error) {
 	podList := &corev1.PodList{}
-	// 构建标签选择器
-	labels := map[string]string{
-		"mystatefulset-name": myStatefulSet.Name,
+	if err := r.List(ctx, podList, client.InNamespace(req.Namespace)); err != nil {
+		return podList, err
 	}
-	listOpts := []client.ListOption{
-		client.InNamespace(req.Namespace),
-		client.MatchingLabels(labels),
+	claimed, err := r.claimPods(ctx, myStatefulSet, podList.Items)
+	if err != nil {
+		return podList, err
 	}
-	err := r.List(ctx, podList, listOpts...)
-	return podList, err
+	podList.Items = claimed
+	return podList, nil
 }
 
-func (r *MyStatefulSetReconciler) createMissingPodsAndPVCs(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, desiredReplicas int32) error {
-	for i := int32(0); i < desiredReplicas; i++ {
-		podName := fmt.Sprintf("%s-%d", myStatefulSet.Name, i)
-		if !podExists(podName, podList) {
-			if err := r.createPVCs(ctx, req, myStatefulSet, i); err != nil {
-				return err
+// createMissingPodsAndPVCs 创建缺失的 Pod 和 PVC。
+// OrderedReady 策略下只有序号 N-1 的 Pod 运行且就绪后才会创建序号 N 的 Pod，
+// 未就绪但需要更新的 Pod 会被直接删除并在之后以更新版本重建，避免错误的发布阻塞回滚或模板修复；
+// Parallel 策略下以有限的并发度同时创建所有缺失的 Pod。
+// 处于 Failed 或 Succeeded 阶段（例如被驱逐）的 Pod 会被删除，并在之后的调谐中重建。
+// Pod 已存在（缓存尚未同步）时不视为失败，重新入队后再观察。
+// 返回值表示是否需要等待 Pod 创建、就绪或终止后重新入队。
+func (r *MyStatefulSetReconciler) createMissingPodsAndPVCs(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, desiredReplicas int32, revisions *revisionState) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	if myStatefulSet.Spec.PodManagementPolicy == k8sappsv1.ParallelPodManagement {
+		var missing []int32
+		var finished []*corev1.Pod
+		for i := getStartOrdinal(myStatefulSet); i < getEndOrdinal(myStatefulSet, desiredReplicas); i++ {
+			pod := findPod(getPodName(myStatefulSet.Name, i), podList)
+			if pod == nil {
+				missing = append(missing, i)
+			} else if pod.DeletionTimestamp == nil && isPodFinished(pod) {
+				finished = append(finished, pod)
 			}
-			if err := r.createPod(ctx, req, myStatefulSet, podName); err != nil {
-				return err
+		}
+		err := runConcurrently(len(missing), func(i int) error {
+			ordinal := missing[i]
+			return r.createPodAndPVCs(ctx, req, myStatefulSet, ordinal, revisionForOrdinal(myStatefulSet, ordinal, revisions))
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, pod := range finished {
+			logger.Info("删除已结束的 Pod，稍后重建", "pod", pod.Name, "phase", pod.Status.Phase)
+		}
+		err = runConcurrently(len(finished), func(i int) error {
+			return r.deletePod(ctx, myStatefulSet, finished[i])
+		})
+		// 新建的 Pod 出现在缓存中之前不继续缩容和更新
+		return len(missing) > 0 || len(finished) > 0, err
+	}
+
+	for i := getStartOrdinal(myStatefulSet); i < getEndOrdinal(myStatefulSet, desiredReplicas); i++ {
+		pod := findPod(getPodName(myStatefulSet.Name, i), podList)
+		if pod == nil {
+			return true, r.createPodAndPVCs(ctx, req, myStatefulSet, i, revisionForOrdinal(myStatefulSet, i, revisions))
+		}
+		if pod.DeletionTimestamp == nil && isPodFinished(pod) {
+			logger.Info("删除已结束的 Pod，稍后重建", "pod", pod.Name, "phase", pod.Status.Phase)
+			return true, r.deletePod(ctx, myStatefulSet, pod)
+		}
+		if !isPodRunningAndReady(pod) {
+			if pod.DeletionTimestamp == nil && isPodOutdated(myStatefulSet, pod, i) {
+				logger.Info("替换未就绪的过期 Pod", "pod", pod.Name)
+				return true, r.deletePod(ctx, myStatefulSet, pod)
 			}
+			logger.Info("等待 Pod 运行并就绪", "pod", pod.Name)
+			return true, nil
 		}
 	}
+	return false, nil
+}
+
+// createPodAndPVCs 创建指定序号的 PVC 和处于 revision 版本的 Pod，失败时返回包装了 errPodCreate 的错误
+func (r *MyStatefulSetReconciler) createPodAndPVCs(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, ordinal int32, revision *k8sappsv1.ControllerRevision) error {
+	podName := getPodName(myStatefulSet.Name, ordinal)
+	if err := r.createPVCs(ctx, req, myStatefulSet, ordinal); err != nil {
+		return fmt.Errorf("%w %s: %w", errPodCreate, podName, err)
+	}
+	if err := r.createPod(ctx, req, myStatefulSet, podName, revision); err != nil {
+		return fmt.Errorf("%w %s: %w", errPodCreate, podName, err)
+	}
 	return nil
 }
 
-func podExists(podName string, podList *corev1.PodList) bool {
-	for _, pod := range podList.Items {
-		if pod.Name == podName {
-			return true
+// findPod 在 Pod 列表中按名称查找 Pod，未找到时返回 nil
+func findPod(podName string, podList *corev1.PodList) *corev1.Pod {
+	for i := range podList.Items {
+		if podList.Items[i].Name == podName {
+			return &podList.Items[i]
+		}
+	}
+	return nil
+}
+
+// isPodRunningAndReady 判断 Pod 是否处于 Running 阶段且 Ready 条件为 True
+func isPodRunningAndReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
 		}
 	}
 	return false
 }
 
+// isPodFinished 判断 Pod 是否处于 Failed 或 Succeeded 阶段，此类 Pod 的容器不会再被重启
+func isPodFinished(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded
+}
+
 func (r *MyStatefulSetReconciler) createPVCs(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, ordinal int32) error {
 	for _, pvcTemplate := range myStatefulSet.Spec.VolumeClaimTemplates {
-		pvcName := fmt.Sprintf("%s-%s-%d", pvcTemplate.Name, myStatefulSet.Name, ordinal)
+		podName := getPodName(myStatefulSet.Name, ordinal)
+		pvcName := getPVCName(pvcTemplate.Name, podName)
+		labels := createLabels(pvcTemplate.Labels, myStatefulSet.Name)
+		r.setIdentityLabels(labels, podName, ordinal)
 		newPVC := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        pvcName,
 				Namespace:   req.Namespace,
-				Labels:      createLabels(pvcTemplate.Labels, myStatefulSet.Name),
+				Labels:      labels,
 				Annotations: pvcTemplate.Annotations,
-				OwnerReferences: []metav1.OwnerReference{
-					*metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")),
-				},
 			},
 			Spec: pvcTemplate.Spec,
 		}
-		if err := r.Create(ctx, newPVC); err != nil && !apierrors.IsAlreadyExists(err) {
+		// 保留策略为 Retain 时不设置属主引用，避免 PVC 随 MyStatefulSet 被垃圾回收
+		if deletePVCsWhenDeleted(myStatefulSet) {
+			newPVC.OwnerReferences = []metav1.OwnerReference{
+				*metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")),
+			}
+		}
+		if err := r.Create(ctx, newPVC); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonFailedCreatePVC, "创建 PVC %s 失败: %v", pvcName, err)
 			return err
 		}
+		r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonSuccessfulCreatePVC, "创建 PVC %s", pvcName)
 	}
 	return nil
 }
 
-func (r *MyStatefulSetReconciler) createPod(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, podName string) error {
+// createPod 使用 revision 中保存的 Pod 模板创建 Pod
+func (r *MyStatefulSetReconciler) createPod(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, podName string, revision *k8sappsv1.ControllerRevision) error {
+	template, err := revisionPodTemplate(revision)
+	if err != nil {
+		return err
+	}
+	labels := createLabels(template.Labels, myStatefulSet.Name)
+	// 使用修订版本名称标记 Pod 所处的版本，并记录创建 Pod 所用模板的哈希
+	labels[k8sappsv1.ControllerRevisionHashLabelKey] = revision.Name
+	labels[templateHashLabel] = templateHash(template)
+	if ordinal, ok := getOrdinal(myStatefulSet.Name, podName); ok {
+		r.setIdentityLabels(labels, podName, ordinal)
+	}
 	newPod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        podName,
 			Namespace:   req.Namespace,
-			Labels:      createLabels(myStatefulSet.Spec.Template.Labels, myStatefulSet.Name),
-			Annotations: myStatefulSet.Spec.Template.Annotations,
+			Labels:      labels,
+			Annotations: template.Annotations,
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")),
 			},
 		},
-		Spec: myStatefulSet.Spec.Template.Spec,
+		Spec: template.Spec,
 	}
+	newPod.Spec.Volumes = podVolumes(myStatefulSet, template.Spec.Volumes, podName)
+	// 稳定的网络标识：<podName>.<serviceName>.<namespace>.svc
+	newPod.Spec.Hostname = podName
+	newPod.Spec.Subdomain = myStatefulSet.Spec.ServiceName
 	if err := r.Create(ctx, newPod); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// 缓存尚未观察到已创建的 Pod，调用方会重新入队等待缓存同步
+			log.FromContext(ctx).Info("Pod 已存在，等待缓存同步", "pod", podName)
+			return nil
+		}
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonFailedCreatePod, "创建 Pod %s 失败: %v", podName, err)
 		return err
 	}
+	r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonSuccessfulCreatePod, "创建 Pod %s", podName)
 
 	return nil
 }
 
-func (r *MyStatefulSetReconciler) updatePods(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, req ctrl.Request) error {
-	for _, pod := range podList.Items {
-		if podNeedsUpdate(&pod, myStatefulSet.Spec.Template) {
-			if err := r.Delete(ctx, &pod); err != nil {
-				return err
-			}
-			time.Sleep(3 * time.Second)
-			// 在删除后重新创建 Pod
-			if err := r.createPod(ctx, req, myStatefulSet, pod.Name); err != nil {
-				return err
-			}
-			break // 一次只更新一个 Pod，确保有序性
+// scaleDown 按序号从大到小删除序号不在 [start, start+desiredReplicas) 范围内的 Pod。
+// OrderedReady 策略下每次只删除一个 Pod，并等待其完全终止后再处理下一个；
+// Parallel 策略下并发删除所有多余的 Pod。返回值表示缩容是否仍在进行中。
+func (r *MyStatefulSetReconciler) scaleDown(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, desiredReplicas int32) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	condemned := condemnedPods(myStatefulSet, podList, desiredReplicas)
+	if len(condemned) == 0 {
+		return false, nil
+	}
+
+	if myStatefulSet.Spec.PodManagementPolicy == k8sappsv1.ParallelPodManagement {
+		err := runConcurrently(len(condemned), func(i int) error {
+			return r.deleteCondemnedPod(ctx, myStatefulSet, condemned[i])
+		})
+		return true, err
+	}
+
+	// OrderedReady：序号最大的 Pod 仍在终止中时不处理下一个
+	pod := condemned[0]
+	if pod.DeletionTimestamp != nil {
+		logger.Info("等待 Pod 终止", "pod", pod.Name)
+		return true, nil
+	}
+	return true, r.deleteCondemnedPod(ctx, myStatefulSet, pod)
+}
+
+// deleteCondemnedPod 删除缩容移除的 Pod，WhenScaled 为 Delete 时同时删除其 PVC
+func (r *MyStatefulSetReconciler) deleteCondemnedPod(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, pod *corev1.Pod) error {
+	if err := r.deletePod(ctx, myStatefulSet, pod); err != nil {
+		return err
+	}
+	if !deletePVCsWhenScaled(myStatefulSet) {
+		return nil
+	}
+	return r.deletePVCs(ctx, myStatefulSet, pod.Name)
+}
+
+// condemnedPods 返回序号不在期望范围内的 Pod，按序号从大到小排序
+func condemnedPods(myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, desiredReplicas int32) []*corev1.Pod {
+	var condemned []*corev1.Pod
+	for i := range podList.Items {
+		ordinal, ok := getOrdinal(myStatefulSet.Name, podList.Items[i].Name)
+		if ok && !isOrdinalInRange(myStatefulSet, ordinal, desiredReplicas) {
+			condemned = append(condemned, &podList.Items[i])
+		}
+	}
+	sortPodsByOrdinalDescending(myStatefulSet.Name, condemned)
+	return condemned
+}
+
+// sortPodsByOrdinalDescending 将 Pod 按序号从大到小排序
+func sortPodsByOrdinalDescending(name string, pods []*corev1.Pod) {
+	sort.Slice(pods, func(i, j int) bool {
+		oi, _ := getOrdinal(name, pods[i].Name)
+		oj, _ := getOrdinal(name, pods[j].Name)
+		return oi > oj
+	})
+}
+
+// deletePod 删除 Pod，已处于终止中或已不存在的 Pod 会被忽略
+func (r *MyStatefulSetReconciler) deletePod(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, pod *corev1.Pod) error {
+	if pod.DeletionTimestamp != nil {
+		return nil
+	}
+	log.FromContext(ctx).Info("删除 Pod", "pod", pod.Name)
+	if err := r.Delete(ctx, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonFailedDeletePod, "删除 Pod %s 失败: %v", pod.Name, err)
+		return err
+	}
+	r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonSuccessfulDeletePod, "删除 Pod %s", pod.Name)
+	return nil
+}
+
+// updatePods 按序号从大到小滚动更新与模板不一致的 Pod。
+// 滚动更新是一个基于重新入队的状态机：本函数只负责删除过期的 Pod 并返回，
+// 后续调谐观察到 Pod 消失后由 createMissingPodsAndPVCs 以更新版本重建，
+// 新 Pod 就绪后不可用预算恢复，才会继续处理下一个序号。
+// 同时不可用的 Pod 数量不会超过 RollingUpdate.MaxUnavailable（默认为 1），在预算内的 Pod 会被并发删除；
+// 已经不可用的过期 Pod 不占用预算，总是最先被替换，避免错误的发布永久阻塞更新；
+// 序号小于 RollingUpdate.Partition 的 Pod 保持在当前版本，不会被更新；
+// OnDelete 策略下控制器从不主动删除 Pod，只在 Pod 被外部删除后以更新版本重建。
+// 返回值表示是否仍有 Pod 等待更新。
+func (r *MyStatefulSetReconciler) updatePods(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	if myStatefulSet.Spec.UpdateStrategy.Type == k8sappsv1.OnDeleteStatefulSetStrategyType {
+		return false, nil
+	}
+
+	desiredReplicas := *myStatefulSet.Spec.Replicas
+
+	var outdated, unavailableOutdated []*corev1.Pod
+	readyReplicas := int32(0)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		ordinal, ok := getOrdinal(myStatefulSet.Name, pod.Name)
+		if !ok || !isOrdinalInRange(myStatefulSet, ordinal, desiredReplicas) {
+			continue
+		}
+		ready := isPodRunningAndReady(pod)
+		if ready {
+			readyReplicas++
+		}
+		// 终止中的 Pod 已在替换过程中，等待其消失后重建
+		if pod.DeletionTimestamp != nil || !isPodOutdated(myStatefulSet, pod, ordinal) {
+			continue
+		}
+		if ready {
+			outdated = append(outdated, pod)
+		} else {
+			unavailableOutdated = append(unavailableOutdated, pod)
+		}
+	}
+	if len(outdated) == 0 && len(unavailableOutdated) == 0 {
+		return false, nil
+	}
+	sortPodsByOrdinalDescending(myStatefulSet.Name, outdated)
+	sortPodsByOrdinalDescending(myStatefulSet.Name, unavailableOutdated)
+
+	// 缺失或未就绪的 Pod 都计入不可用数量，只有可用的过期 Pod 受预算限制
+	maxUnavailable, err := getMaxUnavailable(myStatefulSet)
+	if err != nil {
+		err = fmt.Errorf("无效的 maxUnavailable: %w", err)
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonInvalidSpec, "%s", err.Error())
+		return false, err
+	}
+	budget := max(maxUnavailable-int(desiredReplicas-readyReplicas), 0)
+	if len(outdated) > budget {
+		outdated = outdated[:budget]
+	}
+	toDelete := append(unavailableOutdated, outdated...)
+	if len(toDelete) == 0 {
+		logger.Info("不可用的 Pod 数量已达到上限，等待 Pod 就绪后继续更新", "maxUnavailable", maxUnavailable)
+		return true, nil
+	}
+
+	if r.DebugTemplateDiff {
+		for _, pod := range toDelete {
+			r.logTemplateDiff(ctx, pod, &myStatefulSet.Spec.Template)
+		}
+	}
+
+	err = runConcurrently(len(toDelete), func(i int) error {
+		return r.deletePod(ctx, myStatefulSet, toDelete[i])
+	})
+	return true, err
+}
+
+// rollingUpdateProgress 根据 Pod 推导滚动更新的进度。
+// 进度完全由 Pod 的版本标签和就绪状态决定，控制器重启后可以据此从中断处继续。
+func rollingUpdateProgress(myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, status *appsv1.MyStatefulSetStatus) *appsv1.RollingUpdateStatus {
+	if status.CurrentRevision == status.UpdateRevision {
+		return nil
+	}
+
+	progress := &appsv1.RollingUpdateStatus{Revision: status.UpdateRevision}
+	start := max(getPartition(myStatefulSet), getStartOrdinal(myStatefulSet))
+	for ordinal := start; ordinal < getEndOrdinal(myStatefulSet, *myStatefulSet.Spec.Replicas); ordinal++ {
+		pod := findPod(getPodName(myStatefulSet.Name, ordinal), podList)
+		// 缺失、终止中或已更新但尚未就绪的 Pod 都处于替换过程中
+		if pod == nil || pod.DeletionTimestamp != nil ||
+			(getPodRevision(pod) == status.UpdateRevision && !isPodRunningAndReady(pod)) {
+			progress.Ordinals = append(progress.Ordinals, ordinal)
+		}
+	}
+	return progress
+}
+
+// isPodOutdated 判断 Pod 是否应由控制器更新：非 OnDelete 策略下序号不小于分区且模板与期望模板不一致
+func isPodOutdated(myStatefulSet *appsv1.MyStatefulSet, pod *corev1.Pod, ordinal int32) bool {
+	return myStatefulSet.Spec.UpdateStrategy.Type != k8sappsv1.OnDeleteStatefulSetStrategyType &&
+		ordinal >= getPartition(myStatefulSet) && podNeedsUpdate(pod, myStatefulSet.Spec.Template)
+}
+
+// getMaxUnavailable 返回滚动更新时允许同时不可用的 Pod 数量，百分比按期望副本数向下取整，至少为 1
+func getMaxUnavailable(myStatefulSet *appsv1.MyStatefulSet) (int, error) {
+	maxUnavailable := intstr.FromInt32(1)
+	if rollingUpdate := myStatefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.MaxUnavailable != nil {
+		maxUnavailable = *rollingUpdate.MaxUnavailable
+	}
+	value, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, int(*myStatefulSet.Spec.Replicas), false)
+	if err != nil {
+		return 0, err
+	}
+	return max(value, 1), nil
+}
+
+// getStartOrdinal 返回第一个 Pod 的序号，未设置 Ordinals 时为 0
+func getStartOrdinal(myStatefulSet *appsv1.MyStatefulSet) int32 {
+	if myStatefulSet.Spec.Ordinals == nil {
+		return 0
+	}
+	return myStatefulSet.Spec.Ordinals.Start
+}
+
+// getEndOrdinal 返回期望序号范围的结束值（不包含）
+func getEndOrdinal(myStatefulSet *appsv1.MyStatefulSet, replicas int32) int32 {
+	return getStartOrdinal(myStatefulSet) + replicas
+}
+
+// isOrdinalInRange 判断序号是否处于 [start, start+replicas) 范围内
+func isOrdinalInRange(myStatefulSet *appsv1.MyStatefulSet, ordinal, replicas int32) bool {
+	return ordinal >= getStartOrdinal(myStatefulSet) && ordinal < getEndOrdinal(myStatefulSet, replicas)
+}
+
+// getPartition 返回滚动更新的分区序号，未设置或不是 RollingUpdate 策略时为 0
+func getPartition(myStatefulSet *appsv1.MyStatefulSet) int32 {
+	if myStatefulSet.Spec.UpdateStrategy.Type == k8sappsv1.OnDeleteStatefulSetStrategyType {
+		return 0
+	}
+	rollingUpdate := myStatefulSet.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate == nil || rollingUpdate.Partition == nil {
+		return 0
+	}
+	return *rollingUpdate.Partition
+}
+
+// revisionForOrdinal 返回指定序号的 Pod 应处于的修订版本，序号小于分区的 Pod 使用当前版本
+func revisionForOrdinal(myStatefulSet *appsv1.MyStatefulSet, ordinal int32, revisions *revisionState) *k8sappsv1.ControllerRevision {
+	if ordinal < getPartition(myStatefulSet) {
+		return revisions.current
+	}
+	return revisions.update
+}
+
+// updateStatus 根据观察到的 Pod 计算 MyStatefulSet 的状态，并在状态变化时写回 status 子资源。
+// fullyProcessed 为 true 时表示当前代的配置已被完整处理，此时才推进 ObservedGeneration；
+// syncErr 是本次同步遇到的错误，用于设置 Degraded 和 ReconcileError 条件。
+func (r *MyStatefulSetReconciler) updateStatus(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, revisions *revisionState, fullyProcessed bool, syncErr error) error {
+	podList, err := r.listOwnedPods(ctx, req, myStatefulSet)
+	if err != nil {
+		return err
+	}
+
+	status := *myStatefulSet.Status.DeepCopy()
+	status.Replicas = 0
+	status.ReadyReplicas = 0
+	status.CurrentReplicas = 0
+	status.UpdatedReplicas = 0
+	status.CurrentRevision = revisions.current.Name
+	status.UpdateRevision = revisions.update.Name
+	status.CollisionCount = &revisions.collisionCount
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if _, ok := getOrdinal(myStatefulSet.Name, pod.Name); !ok || pod.DeletionTimestamp != nil {
+			continue
+		}
+		status.Replicas++
+		if isPodRunningAndReady(pod) {
+			status.ReadyReplicas++
 		}
+		if getPodRevision(pod) == revisions.current.Name {
+			status.CurrentReplicas++
+		}
+		if getPodRevision(pod) == revisions.update.Name {
+			status.UpdatedReplicas++
+		}
+	}
+	// 所有 Pod 都已更新且就绪时滚动更新完成，更新版本成为当前版本
+	if status.UpdatedReplicas == status.Replicas && status.ReadyReplicas == status.Replicas {
+		status.CurrentRevision = status.UpdateRevision
+		status.CurrentReplicas = status.UpdatedReplicas
 	}
+	status.RollingUpdate = rollingUpdateProgress(myStatefulSet, podList, &status)
+	if fullyProcessed {
+		status.ObservedGeneration = myStatefulSet.Generation
+	}
+	setConditions(myStatefulSet, &status, podList, syncErr)
+
+	if apiequality.Semantic.DeepEqual(status, myStatefulSet.Status) {
+		return nil
+	}
+	oldStatus := myStatefulSet.Status
+	myStatefulSet.Status = status
+	if err := r.Status().Update(ctx, myStatefulSet); err != nil {
+		return err
+	}
+	r.recordStatusEvents(myStatefulSet, &oldStatus, &status)
 	return nil
 }
 
+// cleanupMyStatefulSet 在 MyStatefulSet 被删除时执行清理，清理完成后才移除 finalizer。
+// 级联模式下按序号从大到小逐个删除 Pod，每个 Pod 完全终止后才处理下一个，WhenDeleted 为 Delete 时同时删除其 PVC；
+// 孤儿模式（kubectl delete --cascade=orphan 或 deletion-policy 注解为 Orphan）下解除属主关系并保留 Pod 和 PVC。
 func (r *MyStatefulSetReconciler) cleanupMyStatefulSet(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
 	podList, err := r.listPods(ctx, req, myStatefulSet)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	for _, pod := range podList.Items {
-		if err := r.Delete(ctx, &pod); err != nil {
+	if isOrphanDeletion(myStatefulSet) {
+		if err := r.releaseOwnedObjects(ctx, req, myStatefulSet, podList); err != nil {
 			return ctrl.Result{}, err
 		}
-		if err := r.deletePVCs(ctx, myStatefulSet, pod.Name); err != nil {
-			return ctrl.Result{}, err
+	} else {
+		pods := make([]*corev1.Pod, 0, len(podList.Items))
+		for i := range podList.Items {
+			if _, ok := getOrdinal(myStatefulSet.Name, podList.Items[i].Name); ok {
+				pods = append(pods, &podList.Items[i])
+			}
+		}
+		if len(pods) > 0 {
+			sortPodsByOrdinalDescending(myStatefulSet.Name, pods)
+			pod := pods[0]
+			if pod.DeletionTimestamp != nil {
+				logger.Info("等待 Pod 终止", "pod", pod.Name)
+				return ctrl.Result{RequeueAfter: requeueInterval}, nil
+			}
+			if err := r.deletePod(ctx, myStatefulSet, pod); err != nil {
+				return ctrl.Result{}, err
+			}
+			if deletePVCsWhenDeleted(myStatefulSet) {
+				if err := r.deletePVCs(ctx, myStatefulSet, pod.Name); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return ctrl.Result{RequeueAfter: requeueInterval}, nil
 		}
 	}
-	return ctrl.Result{}, nil
+
+	logger.Info("MyStatefulSet 清理完成，移除 finalizer")
+	controllerutil.RemoveFinalizer(myStatefulSet, myStatefulSetFinalizer)
+	return ctrl.Result{}, r.Update(ctx, myStatefulSet)
+}
+
+// isOrphanDeletion 判断删除 MyStatefulSet 时是否应保留 Pod 和 PVC
+func isOrphanDeletion(myStatefulSet *appsv1.MyStatefulSet) bool {
+	return controllerutil.ContainsFinalizer(myStatefulSet, metav1.FinalizerOrphanDependents) ||
+		myStatefulSet.Annotations[deletionPolicyAnnotation] == deletionPolicyOrphan
+}
+
+// releaseOwnedObjects 解除 MyStatefulSet 与其 Pod 和 PVC 之间的属主关系，避免它们被垃圾回收
+func (r *MyStatefulSetReconciler) releaseOwnedObjects(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList) error {
+	pvcList, err := r.listPVCs(ctx, req, myStatefulSet)
+	if err != nil {
+		return err
+	}
+
+	objects := make([]client.Object, 0, len(podList.Items)+len(pvcList.Items))
+	for i := range podList.Items {
+		objects = append(objects, &podList.Items[i])
+	}
+	for i := range pvcList.Items {
+		objects = append(objects, &pvcList.Items[i])
+	}
+	for _, obj := range objects {
+		if !removeOwnerReference(obj, myStatefulSet) {
+			continue
+		}
+		log.FromContext(ctx).Info("解除属主关系并保留对象", "object", obj.GetName())
+		if err := r.Update(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeOwnerReference 从 obj 中移除指向 owner 的属主引用，返回是否发生了变化
+func removeOwnerReference(obj metav1.Object, owner metav1.Object) bool {
+	refs := obj.GetOwnerReferences()
+	kept := make([]metav1.OwnerReference, 0, len(refs))
+	for _, ref := range refs {
+		if ref.UID != owner.GetUID() {
+			kept = append(kept, ref)
+		}
+	}
+	if len(kept) == len(refs) {
+		return false
+	}
+	obj.SetOwnerReferences(kept)
+	return true
 }
 
 func (r *MyStatefulSetReconciler) deletePVCs(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, podName string) error {
 	for _, pvcTemplate := range myStatefulSet.Spec.VolumeClaimTemplates {
-		pvcName := fmt.Sprintf("%s-%s", pvcTemplate.Name, podName)
+		pvcName := getPVCName(pvcTemplate.Name, podName)
 		pvc := &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      pvcName,
 				Namespace: myStatefulSet.Namespace,
 			},
 		}
-		if err := r.Delete(ctx, pvc); err != nil && !apierrors.IsNotFound(err) {
+		if err := r.deletePVC(ctx, myStatefulSet, pvc); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// podNeedsUpdate 判断 Pod 是否需要更新
-func podNeedsUpdate(pod *corev1.Pod, desiredPodTemplate corev1.PodTemplateSpec) bool {
-	// 检查容器数量是否一致
-	if len(pod.Spec.Containers) != len(desiredPodTemplate.Spec.Containers) {
-		return true
+// deletePVC 删除 PVC 并记录事件，已不存在的 PVC 会被忽略
+func (r *MyStatefulSetReconciler) deletePVC(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, pvc *corev1.PersistentVolumeClaim) error {
+	if err := r.Delete(ctx, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonFailedDeletePVC, "删除 PVC %s 失败: %v", pvc.Name, err)
+		return err
 	}
+	r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonSuccessfulDeletePVC, "删除 PVC %s", pvc.Name)
+	return nil
+}
 
-	// 检查每个容器的镜像版本
-	for i, container := range pod.Spec.Containers {
-		desiredContainer := desiredPodTemplate.Spec.Containers[i]
+// podNeedsUpdate 判断 Pod 是否需要更新。
+// 创建 Pod 时会在标签中记录完整 Pod 模板的哈希，只要期望模板的任意字段发生变化哈希就会不同；
+// 没有哈希标签的 Pod 无法确认其模板，同样视为需要更新。
+func podNeedsUpdate(pod *corev1.Pod, desiredPodTemplate corev1.PodTemplateSpec) bool {
+	return pod.Labels[templateHashLabel] != templateHash(&desiredPodTemplate)
+}
 
-		// 如果镜像名称或版本不一致，则需要更新
-		if container.Image != desiredContainer.Image {
-			return true
-		}
-	}
+// logTemplateDiff 记录 Pod 所处修订版本的模板与期望模板之间的字段差异
+func (r *MyStatefulSetReconciler) logTemplateDiff(ctx context.Context, pod *corev1.Pod, desiredPodTemplate *corev1.PodTemplateSpec) {
+	logger := log.FromContext(ctx)
 
-	// 检查标签是否一致
-	for key, value := range desiredPodTemplate.Labels {
-		if pod.Labels[key] != value {
-			return true
-		}
+	revision := &k8sappsv1.ControllerRevision{}
+	key := client.ObjectKey{Namespace: pod.Namespace, Name: getPodRevision(pod)}
+	if err := r.Get(ctx, key, revision); err != nil {
+		logger.Info("无法获取 Pod 所处的修订版本，跳过模板差异输出", "pod", pod.Name, "revision", key.Name, "error", err.Error())
+		return
 	}
-
-	// 检查注解是否一致
-	for key, value := range desiredPodTemplate.Annotations {
-		if pod.Annotations[key] != value {
-			return true
-		}
+	template, err := revisionPodTemplate(revision)
+	if err != nil {
+		logger.Info("无法解析 Pod 所处的修订版本，跳过模板差异输出", "pod", pod.Name, "revision", key.Name, "error", err.Error())
+		return
 	}
-
-	// 如果没有需要更新的地方，则返回 false
-	return false
+	logger.Info("Pod 模板与期望模板不一致", "pod", pod.Name, "revision", key.Name, "diff", templateDiff(template, desiredPodTemplate))
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *MyStatefulSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&appsv1.MyStatefulSet{}).
+		Owns(&corev1.Pod{}, builder.WithPredicates(podChangedPredicate)).
+		Owns(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(pvcChangedPredicate)).
+		Owns(&corev1.Service{}).
 		Complete(r)
 }
 
+// podChangedPredicate 只放行会影响调谐结果的 Pod 更新：删除标记、阶段、就绪状态、标签、属主，
+// 以及容器的等待原因或重启次数发生变化（用于及时反映 CrashLoopBackOff 等状态）。
+// 创建和删除事件全部放行，以便及时重建被删除或驱逐的 Pod。
+var podChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPod, ok := e.ObjectOld.(*corev1.Pod)
+		if !ok {
+			return false
+		}
+		newPod, ok := e.ObjectNew.(*corev1.Pod)
+		if !ok {
+			return false
+		}
+		return !oldPod.DeletionTimestamp.Equal(newPod.DeletionTimestamp) ||
+			oldPod.Status.Phase != newPod.Status.Phase ||
+			isPodRunningAndReady(oldPod) != isPodRunningAndReady(newPod) ||
+			!apiequality.Semantic.DeepEqual(oldPod.Labels, newPod.Labels) ||
+			!apiequality.Semantic.DeepEqual(oldPod.OwnerReferences, newPod.OwnerReferences) ||
+			containerStatusesChanged(oldPod.Status.InitContainerStatuses, newPod.Status.InitContainerStatuses) ||
+			containerStatusesChanged(oldPod.Status.ContainerStatuses, newPod.Status.ContainerStatuses)
+	},
+}
+
+// containerStatusesChanged 判断容器的等待原因或重启次数是否发生变化
+func containerStatusesChanged(oldStatuses, newStatuses []corev1.ContainerStatus) bool {
+	if len(oldStatuses) != len(newStatuses) {
+		return true
+	}
+	for i := range oldStatuses {
+		if oldStatuses[i].RestartCount != newStatuses[i].RestartCount ||
+			waitingReason(&oldStatuses[i]) != waitingReason(&newStatuses[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitingReason 返回容器处于等待状态的原因，未处于等待状态时返回空字符串
+func waitingReason(containerStatus *corev1.ContainerStatus) string {
+	if containerStatus.State.Waiting == nil {
+		return ""
+	}
+	return containerStatus.State.Waiting.Reason
+}
+
+// pvcChangedPredicate 只放行删除标记、阶段或属主发生变化的 PVC 更新
+var pvcChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPVC, ok := e.ObjectOld.(*corev1.PersistentVolumeClaim)
+		if !ok {
+			return false
+		}
+		newPVC, ok := e.ObjectNew.(*corev1.PersistentVolumeClaim)
+		if !ok {
+			return false
+		}
+		return !oldPVC.DeletionTimestamp.Equal(newPVC.DeletionTimestamp) ||
+			oldPVC.Status.Phase != newPVC.Status.Phase ||
+			!apiequality.Semantic.DeepEqual(oldPVC.OwnerReferences, newPVC.OwnerReferences)
+	},
+}
+
 // createLabels 创建一个包含 MyStatefulSet 的模板标签和名称的标签集合
 func createLabels(oldMap map[string]string, name string) map[string]string {
 	// 创建一个新的 map 来存储合并后的标签
@@ -276,3 +946,75 @@ func createLabels(oldMap map[string]string, name string) map[string]string {
 
 	return labels
 }
+
+// setIdentityLabels 写入标识单个序号的 pod-name 和 pod-index 标签，便于按序号选择 Pod 或 PVC
+func (r *MyStatefulSetReconciler) setIdentityLabels(labels map[string]string, podName string, ordinal int32) {
+	prefix := r.IdentityLabelPrefix
+	if prefix == "" {
+		prefix = defaultIdentityLabelPrefix
+	}
+	labels[prefix+"/pod-name"] = podName
+	labels[prefix+"/pod-index"] = strconv.Itoa(int(ordinal))
+}
+
+// getPodName 返回序号为 ordinal 的 Pod 名称，格式为 <name>-<ordinal>
+func getPodName(name string, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", name, ordinal)
+}
+
+// getPVCName 返回 Pod 使用 pvcTemplateName 模板创建的 PVC 名称，格式为 <模板名>-<Pod 名>
+func getPVCName(pvcTemplateName, podName string) string {
+	return fmt.Sprintf("%s-%s", pvcTemplateName, podName)
+}
+
+// podVolumes 为每个 VolumeClaimTemplates 生成引用该 Pod 对应 PVC 的卷，
+// 模板中同名的卷会被替换，容器可以直接按模板名称挂载
+func podVolumes(myStatefulSet *appsv1.MyStatefulSet, templateVolumes []corev1.Volume, podName string) []corev1.Volume {
+	claims := make(map[string]corev1.Volume, len(myStatefulSet.Spec.VolumeClaimTemplates))
+	for _, pvcTemplate := range myStatefulSet.Spec.VolumeClaimTemplates {
+		claims[pvcTemplate.Name] = corev1.Volume{
+			Name: pvcTemplate.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: getPVCName(pvcTemplate.Name, podName),
+				},
+			},
+		}
+	}
+
+	volumes := make([]corev1.Volume, 0, len(templateVolumes)+len(claims))
+	for _, volume := range templateVolumes {
+		if _, ok := claims[volume.Name]; !ok {
+			volumes = append(volumes, volume)
+		}
+	}
+	for _, pvcTemplate := range myStatefulSet.Spec.VolumeClaimTemplates {
+		volumes = append(volumes, claims[pvcTemplate.Name])
+	}
+	return volumes
+}
+
+// getOrdinal 从 Pod 名称 <name>-<ordinal> 中解析序号
+func getOrdinal(name, podName string) (int32, bool) {
+	suffix, found := strings.CutPrefix(podName, name+"-")
+	if !found {
+		return 0, false
+	}
+	ordinal, err := strconv.ParseInt(suffix, 10, 32)
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
+
+// runConcurrently 以有限的并发度执行 n 次 fn，返回遇到的第一个错误
+func runConcurrently(n int, fn func(i int) error) error {
+	var g errgroup.Group
+	g.SetLimit(maxConcurrentPodOperations)
+	for i := 0; i < n; i++ {
+		g.Go(func() error {
+			return fn(i)
+		})
+	}
+	return g.Wait()
+}
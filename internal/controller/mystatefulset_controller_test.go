@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
 	"testing"
@@ -9,13 +10,20 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	k8sappsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	appsv1 "my.com/devops-golang-test/api/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -30,6 +38,7 @@ var _ = Describe("MyStatefulSet Controller", func() {
 		k8sClient            client.Client
 		scheme               *runtime.Scheme
 		controllerReconciler *MyStatefulSetReconciler
+		recorder             *record.FakeRecorder
 	)
 
 	BeforeEach(func() {
@@ -41,12 +50,17 @@ var _ = Describe("MyStatefulSet Controller", func() {
 		Expect(appsv1.AddToScheme(scheme)).To(Succeed())
 
 		// 创建 fake client
-		k8sClient = fake.NewClientBuilder().WithScheme(scheme).Build()
+		k8sClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&appsv1.MyStatefulSet{}, &corev1.Pod{}).
+			Build()
 
 		// 初始化控制器
+		recorder = record.NewFakeRecorder(100)
 		controllerReconciler = &MyStatefulSetReconciler{
-			Client: k8sClient,
-			Scheme: scheme,
+			Client:   k8sClient,
+			Scheme:   scheme,
+			Recorder: recorder,
 		}
 	})
 
@@ -85,6 +99,7 @@ var _ = Describe("MyStatefulSet Controller", func() {
 					},
 					VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
 						{
+							ObjectMeta: metav1.ObjectMeta{Name: "data"},
 							Spec: corev1.PersistentVolumeClaimSpec{
 								AccessModes: []corev1.PersistentVolumeAccessMode{
 									corev1.ReadWriteOnce, // 访问模式
@@ -104,15 +119,12 @@ var _ = Describe("MyStatefulSet Controller", func() {
 
 		It("should create the expected number of Pods", func() {
 			By("Reconciling the created resource")
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
-			})
-			Expect(err).NotTo(HaveOccurred())
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
 
 			By("Checking if the expected number of Pods are created")
 			Eventually(func() int {
 				podList := &corev1.PodList{}
-				err = k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})
+				err := k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})
 				Expect(err).NotTo(HaveOccurred())
 				return len(podList.Items)
 			}, time.Second*5, time.Millisecond*500).Should(Equal(3))
@@ -120,13 +132,10 @@ var _ = Describe("MyStatefulSet Controller", func() {
 
 		It("should update Pods when the MyStatefulSet is updated, images", func() {
 			By("Updating the MyStatefulSet resource")
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
 			mystatefulset := &appsv1.MyStatefulSet{}
 			err := k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
 			Expect(err).NotTo(HaveOccurred())
-			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
-			})
-			Expect(err).NotTo(HaveOccurred())
 
 			// 假设更新的是镜像版本
 			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
@@ -138,27 +147,27 @@ var _ = Describe("MyStatefulSet Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			// 验证Pod是否被更新
+			By("Reconciling again to recreate the deleted Pod")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			// 验证序号最大的Pod是否被最先更新
 			Eventually(func() string {
-				podList := &corev1.PodList{}
-				err = k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})
+				pod := &corev1.Pod{}
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)
 				Expect(err).NotTo(HaveOccurred())
-				if len(podList.Items) > 0 {
-					return podList.Items[0].Spec.Containers[0].Image
-				}
-				return ""
+				return pod.Spec.Containers[0].Image
 			}, time.Second*5, time.Millisecond*500).Should(Equal("nginx:1.19"))
 		})
 
 		It("should update Pods when the MyStatefulSet is updated, labels", func() {
 			By("Updating the MyStatefulSet resource")
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
 			mystatefulset := &appsv1.MyStatefulSet{}
 			err := k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
 			Expect(err).NotTo(HaveOccurred())
-			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
-			})
-			Expect(err).NotTo(HaveOccurred())
 
 			// 假设更新的是标签
 			mystatefulset.Spec.Template.ObjectMeta.Labels = map[string]string{"app": resourceName, "test": "change"}
@@ -170,27 +179,27 @@ var _ = Describe("MyStatefulSet Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			// 验证Pod是否被更新
+			By("Reconciling again to recreate the deleted Pod")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			// 验证序号最大的Pod是否被最先更新
 			Eventually(func() string {
-				podList := &corev1.PodList{}
-				err = k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})
+				pod := &corev1.Pod{}
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)
 				Expect(err).NotTo(HaveOccurred())
-				if len(podList.Items) > 0 {
-					return podList.Items[0].ObjectMeta.Labels["test"]
-				}
-				return ""
+				return pod.ObjectMeta.Labels["test"]
 			}, time.Second*5, time.Millisecond*500).Should(Equal("change"))
 		})
 
 		It("should update Pods when the MyStatefulSet is updated, annotations", func() {
 			By("Updating the MyStatefulSet resource")
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
 			mystatefulset := &appsv1.MyStatefulSet{}
 			err := k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
 			Expect(err).NotTo(HaveOccurred())
-			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
-			})
-			Expect(err).NotTo(HaveOccurred())
 
 			// 假设更新的是注解
 			mystatefulset.Spec.Template.ObjectMeta.Annotations = map[string]string{"test": "change"}
@@ -202,78 +211,1385 @@ var _ = Describe("MyStatefulSet Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			// 验证Pod是否被更新
+			By("Reconciling again to recreate the deleted Pod")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			// 验证序号最大的Pod是否被最先更新
 			Eventually(func() string {
-				podList := &corev1.PodList{}
-				err = k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})
+				pod := &corev1.Pod{}
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)
 				Expect(err).NotTo(HaveOccurred())
-				if len(podList.Items) > 0 {
-					return podList.Items[0].ObjectMeta.Annotations["test"]
-				}
-				return ""
+				return pod.ObjectMeta.Annotations["test"]
 			}, time.Second*5, time.Millisecond*500).Should(Equal("change"))
 		})
 
+		It("should update Pods when any field of the template changes, env", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+			controllerReconciler.DebugTemplateDiff = true
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "MODE", Value: "debug"}}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Spec.Containers[0].Env).To(ConsistOf(corev1.EnvVar{Name: "MODE", Value: "debug"}))
+			Expect(pod.Labels).To(HaveKeyWithValue(templateHashLabel, templateHash(&mystatefulset.Spec.Template)))
+			Expect(podNeedsUpdate(pod, mystatefulset.Spec.Template)).To(BeFalse())
+		})
+
+		It("should keep Pods created before the template hash existed when they still match the template", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			By("Stripping the hash and revision labels like an older controller would have left them")
+			podList := &corev1.PodList{}
+			Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			for i := range podList.Items {
+				pod := &podList.Items[i]
+				delete(pod.Labels, templateHashLabel)
+				delete(pod.Labels, k8sappsv1.ControllerRevisionHashLabelKey)
+				if pod.Annotations == nil {
+					pod.Annotations = map[string]string{}
+				}
+				pod.Annotations["legacy"] = "true"
+				if pod.Name == resourceName+"-1" {
+					pod.Spec.Containers[0].Image = "nginx:1.19"
+				}
+				Expect(k8sClient.Update(ctx, pod)).To(Succeed())
+			}
+
+			for i := 0; i < 3; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			for _, name := range []string{resourceName + "-0", resourceName + "-2"} {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, pod)).To(Succeed())
+				Expect(pod.Annotations).To(HaveKey("legacy"))
+				Expect(pod.Labels).To(HaveKeyWithValue(templateHashLabel, templateHash(&mystatefulset.Spec.Template)))
+				Expect(pod.Labels).To(HaveKeyWithValue(k8sappsv1.ControllerRevisionHashLabelKey, mystatefulset.Status.UpdateRevision))
+			}
+
+			By("Replacing the Pod that no longer matches the template")
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Annotations).NotTo(HaveKey("legacy"))
+			Expect(pod.Spec.Containers[0].Image).To(Equal("nginx:latest"))
+		})
+
+		It("should treat Pods without a template hash as outdated and report template diffs", func() {
+			template := corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			Expect(podNeedsUpdate(&corev1.Pod{}, template)).To(BeTrue())
+
+			desired := template.DeepCopy()
+			desired.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+			Expect(templateHash(desired)).NotTo(Equal(templateHash(&template)))
+			Expect(templateDiff(&template, desired)).To(ContainSubstring("Tolerations"))
+		})
+
 		It("should clean up Pods and PVCs when the MyStatefulSet is deleted", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PersistentVolumeClaimRetentionPolicy = &k8sappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+			}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			By("Reconciling registers the finalizer")
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Finalizers).To(ContainElement(myStatefulSetFinalizer))
+
+			countPods := func() int {
+				podList := &corev1.PodList{}
+				Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+				return len(podList.Items)
+			}
+			countPVCs := func() int {
+				pvcList := &corev1.PersistentVolumeClaimList{}
+				Expect(k8sClient.List(ctx, pvcList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+				return len(pvcList.Items)
+			}
+			Expect(countPods()).To(Equal(3))
+			Expect(countPVCs()).To(Equal(3))
+
 			By("Deleting the MyStatefulSet resource")
+			Expect(k8sClient.Delete(ctx, mystatefulset)).To(Succeed())
+
+			By("Reconciling tears down the highest ordinal first")
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(countPods()).To(Equal(2))
+			Expect(countPVCs()).To(Equal(2))
+			pod := &corev1.Pod{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			By("Reconciling until the teardown completes")
+			for i := 0; i < 2; i++ {
+				_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(countPods()).To(Equal(0))
+			Expect(countPVCs()).To(Equal(0))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Finalizers).To(ContainElement(myStatefulSetFinalizer))
+
+			By("Removing the finalizer once nothing is left")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should retain PVCs by default when the MyStatefulSet is deleted", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+			pvcList := &corev1.PersistentVolumeClaimList{}
+			Expect(k8sClient.List(ctx, pvcList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(pvcList.Items).To(HaveLen(3))
+			for _, pvc := range pvcList.Items {
+				Expect(pvc.OwnerReferences).To(BeEmpty())
+			}
+
 			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, mystatefulset)).To(Succeed())
+			for i := 0; i < 4; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
 			err := k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			podList := &corev1.PodList{}
+			Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(podList.Items).To(BeEmpty())
+			Expect(k8sClient.List(ctx, pvcList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(pvcList.Items).To(HaveLen(3))
+		})
+
+		It("should adjust PVC owner references and delete PVCs on scale-down according to the retention policy", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
 			Expect(err).NotTo(HaveOccurred())
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(1)
+			mystatefulset.Spec.PersistentVolumeClaimRetentionPolicy = &k8sappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+				WhenScaled:  k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+			}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
 			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
-			mystatefulset.Finalizers = []string{"test.finalizer"}
+			Expect(err).NotTo(HaveOccurred())
+
+			pvcList := &corev1.PersistentVolumeClaimList{}
+			Expect(k8sClient.List(ctx, pvcList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(pvcList.Items).To(HaveLen(1))
+			Expect(pvcList.Items[0].Name).To(Equal("data-" + resourceName + "-0"))
+			Expect(metav1.IsControlledBy(&pvcList.Items[0], mystatefulset)).To(BeTrue())
+		})
+
+		It("should orphan Pods and PVCs when the deletion policy is Orphan", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Annotations = map[string]string{deletionPolicyAnnotation: deletionPolicyOrphan}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, mystatefulset)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			podList := &corev1.PodList{}
+			Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(podList.Items).To(HaveLen(3))
+			for _, pod := range podList.Items {
+				Expect(pod.OwnerReferences).To(BeEmpty())
+			}
+			pvcList := &corev1.PersistentVolumeClaimList{}
+			Expect(k8sClient.List(ctx, pvcList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(pvcList.Items).To(HaveLen(3))
+			for _, pvc := range pvcList.Items {
+				Expect(pvc.OwnerReferences).To(BeEmpty())
+			}
+		})
+
+		It("should mount the PVC of each volumeClaimTemplate into the Pod", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Volumes = []corev1.Volume{
+				{Name: "config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Spec.Volumes).To(HaveLen(2))
+			Expect(pod.Spec.Volumes[0].Name).To(Equal("config"))
+			Expect(pod.Spec.Volumes[1].Name).To(Equal("data"))
+			Expect(pod.Spec.Volumes[1].EmptyDir).To(BeNil())
+			Expect(pod.Spec.Volumes[1].PersistentVolumeClaim).NotTo(BeNil())
+			Expect(pod.Spec.Volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("data-" + resourceName + "-1"))
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "data-" + resourceName + "-1", Namespace: "default"}, pvc)).To(Succeed())
+		})
+
+		It("should give each Pod a stable hostname and subdomain", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.ServiceName = "test-service"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Spec.Hostname).To(Equal(resourceName + "-0"))
+			Expect(pod.Spec.Subdomain).To(Equal("test-service"))
+
+			By("Not creating a Service unless manageService is set")
+			service := &corev1.Service{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-service", Namespace: "default"}, service)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should create and update the governing headless Service when manageService is set", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.ServiceName = "test-service"
+			mystatefulset.Spec.ManageService = true
+			mystatefulset.Spec.Template.Spec.Containers[0].Ports = []corev1.ContainerPort{{Name: "http", ContainerPort: 80}}
 			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
 
-			By("Reconciling the deleted resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-service", Namespace: "default"}, service)).To(Succeed())
+			Expect(service.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			Expect(service.Spec.Selector).To(Equal(map[string]string{"mystatefulset-name": resourceName}))
+			Expect(service.Spec.Ports).To(HaveLen(1))
+			Expect(service.Spec.Ports[0].Name).To(Equal("http"))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(80)))
+			Expect(metav1.IsControlledBy(service, mystatefulset)).To(BeTrue())
+
+			By("Updating the Service when the template ports change")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Ports = append(mystatefulset.Spec.Template.Spec.Containers[0].Ports, corev1.ContainerPort{ContainerPort: 9090})
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
 			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// 验证Pod和PVC是否被删除
-			Eventually(func() int {
-				podList := &corev1.PodList{}
-				err = k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})
-				Expect(err).NotTo(HaveOccurred())
-				return len(podList.Items)
-			}, time.Second*5, time.Millisecond*500).Should(Equal(0))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-service", Namespace: "default"}, service)).To(Succeed())
+			Expect(service.Spec.Ports).To(HaveLen(2))
+			Expect(service.Spec.Ports[1].Name).To(Equal("port-tcp-9090"))
 
-			Eventually(func() int {
-				pvcList := &corev1.PersistentVolumeClaimList{}
-				err = k8sClient.List(ctx, pvcList, client.InNamespace("default"), client.MatchingLabels{"app": resourceName})
-				Expect(err).NotTo(HaveOccurred())
-				return len(pvcList.Items)
-			}, time.Second*5, time.Millisecond*500).Should(Equal(0))
+			By("Naming unnamed ports by protocol and skipping duplicate ports")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Ports = append(mystatefulset.Spec.Template.Spec.Containers[0].Ports,
+				corev1.ContainerPort{ContainerPort: 53, Protocol: corev1.ProtocolTCP},
+				corev1.ContainerPort{ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+			)
+			mystatefulset.Spec.Template.Spec.Containers = append(mystatefulset.Spec.Template.Spec.Containers, corev1.Container{
+				Name:  "sidecar",
+				Image: "busybox",
+				Ports: []corev1.ContainerPort{{ContainerPort: 9090}},
+			})
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-service", Namespace: "default"}, service)).To(Succeed())
+			names := []string{}
+			for _, port := range service.Spec.Ports {
+				names = append(names, port.Name)
+			}
+			Expect(names).To(Equal([]string{"http", "port-tcp-9090", "port-tcp-53", "port-udp-53"}))
 		})
 
-		It("other", func() {
-			By("Not Found")
+		It("should keep syncing Pods when the headless Service cannot be synced", func() {
+			controllerReconciler.Client = interceptor.NewClient(k8sClient.(client.WithWatch), interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					if _, ok := obj.(*corev1.Service); ok {
+						return fmt.Errorf("service quota exceeded")
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			})
 			mystatefulset := &appsv1.MyStatefulSet{}
-			err := k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.ServiceName = "test-service"
+			mystatefulset.Spec.ManageService = true
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).To(MatchError(ContainSubstring("service quota exceeded")))
+
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(recorder.Events).To(Receive(ContainSubstring(eventReasonFailedSyncService)))
+		})
+
+		It("should adopt orphan Pods and PVCs and ignore Pods owned by other controllers", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(2)
+			mystatefulset.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": resourceName}}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			orphan := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-1", Namespace: "default", Labels: map[string]string{"app": resourceName}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			Expect(k8sClient.Create(ctx, orphan)).To(Succeed())
+			orphan.Status.Phase = corev1.PodRunning
+			orphan.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, orphan)).To(Succeed())
+			isController := true
+			foreign := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foreign-0",
+					Namespace: "default",
+					Labels:    map[string]string{"app": resourceName},
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "foreign", UID: "foreign-uid", Controller: &isController},
+					},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+			orphanPVC := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data-" + resourceName + "-1", Namespace: "default"},
+			}
+			Expect(k8sClient.Create(ctx, orphanPVC)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
 			Expect(err).NotTo(HaveOccurred())
-			mystatefulset.Spec.Replicas = int32Ptr(1)
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(metav1.IsControlledBy(pod, mystatefulset)).To(BeTrue())
+			Expect(pod.Labels).To(HaveKeyWithValue("mystatefulset-name", resourceName))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(metav1.IsControlledBy(pod, mystatefulset)).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "foreign-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(metav1.IsControlledBy(pod, mystatefulset)).To(BeFalse())
+			Expect(pod.OwnerReferences).To(HaveLen(1))
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "data-" + resourceName + "-1", Namespace: "default"}, pvc)).To(Succeed())
+			Expect(pvc.Labels).To(HaveKeyWithValue("mystatefulset-name", resourceName))
+		})
+
+		It("should release Pods whose labels no longer match the selector", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": resourceName}}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			pod.Labels["app"] = "something-else"
+			Expect(k8sClient.Update(ctx, pod)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			By("Listing owned Pods without releasing anything")
+			podList, err := controllerReconciler.listOwnedPods(ctx, reconcile.Request{NamespacedName: typeNamespacedName}, mystatefulset)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podList.Items).To(HaveLen(2))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(metav1.IsControlledBy(pod, mystatefulset)).To(BeTrue())
+
+			podList, err = controllerReconciler.listPods(ctx, reconcile.Request{NamespacedName: typeNamespacedName}, mystatefulset)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podList.Items).To(HaveLen(2))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.OwnerReferences).To(BeEmpty())
+		})
+
+		It("should label Pods and PVCs with their ordinal identity", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Labels).To(HaveKeyWithValue("apps.my.com/pod-name", resourceName+"-1"))
+			Expect(pod.Labels).To(HaveKeyWithValue("apps.my.com/pod-index", "1"))
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "data-" + resourceName + "-1", Namespace: "default"}, pvc)).To(Succeed())
+			Expect(pvc.Labels).To(HaveKeyWithValue("apps.my.com/pod-name", resourceName+"-1"))
+			Expect(pvc.Labels).To(HaveKeyWithValue("apps.my.com/pod-index", "1"))
+
+			By("Using a configured prefix")
+			controllerReconciler.IdentityLabelPrefix = "example.com"
+			Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Labels).To(HaveKeyWithValue("example.com/pod-name", resourceName+"-1"))
+			Expect(pod.Labels).To(HaveKeyWithValue("example.com/pod-index", "1"))
+		})
+
+		It("should shift Pod and PVC ordinals by ordinals.start", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Ordinals = &k8sappsv1.StatefulSetOrdinals{Start: 5}
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			podNames := func() []string {
+				podList := &corev1.PodList{}
+				Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+				var names []string
+				for _, pod := range podList.Items {
+					names = append(names, pod.Name)
+				}
+				return names
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podNames()).To(ConsistOf(resourceName+"-5", resourceName+"-6", resourceName+"-7"))
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "data-" + resourceName + "-5", Namespace: "default"}, pvc)).To(Succeed())
+
+			By("Scaling down removes the highest ordinal")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(2)
 			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
 			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(podNames()).To(ConsistOf(resourceName+"-5", resourceName+"-6"))
 
-			mystatefulset.Spec.Replicas = nil
+			By("Raising the start ordinal removes ordinals below it and creates ordinals above")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Ordinals.Start = 6
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			for i := 0; i < 2; i++ {
+				_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(podNames()).To(ConsistOf(resourceName+"-6", resourceName+"-7"))
+		})
+
+		It("should create Pods one by one under OrderedReady", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
 			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			countPods := func() int {
+				podList := &corev1.PodList{}
+				Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+				return len(podList.Items)
+			}
+
+			By("Reconciling creates only the first ordinal")
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(countPods()).To(Equal(1))
+
+			By("Reconciling again without the Pod becoming ready")
 			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
-			Eventually(err.Error()).Should(Equal("配置缺少期望的副本数量"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(countPods()).To(Equal(1))
 
-			Expect(k8sClient.Delete(ctx, mystatefulset)).To(Succeed())
+			By("Marking the Pod ready and reconciling")
+			markPodsReady(ctx, k8sClient, resourceName)
 			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(countPods()).To(Equal(2))
 		})
-	})
-})
 
-func int32Ptr(i int32) *int32 {
-	return &i
+		It("should wait for the cache instead of failing when a Pod already exists", func() {
+			existing := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-0", Namespace: "default"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			podList := &corev1.PodList{}
+			revisions, err := controllerReconciler.syncRevisions(ctx, reconcile.Request{NamespacedName: typeNamespacedName}, mystatefulset)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Create(ctx, existing)).To(Succeed())
+
+			By("Creating a Pod that a stale list did not show")
+			waiting, err := controllerReconciler.createMissingPodsAndPVCs(ctx, reconcile.Request{NamespacedName: typeNamespacedName}, mystatefulset, podList, 3, revisions)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(waiting).To(BeTrue())
+			for len(recorder.Events) > 0 {
+				Expect(<-recorder.Events).NotTo(ContainSubstring(eventReasonFailedCreatePod))
+			}
+		})
+
+		It("should delete and recreate evicted or completed Pods", func() {
+			reconcileOnce := func() {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			setPodPhase := func(ordinal int, phase corev1.PodPhase) {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}, pod)).To(Succeed())
+				pod.Status.Phase = phase
+				pod.Status.Reason = "Evicted"
+				pod.Status.Conditions = nil
+				Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+			}
+			expectRecreated := func(ordinal int) {
+				pod := &corev1.Pod{}
+				key := types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}
+				Expect(apierrors.IsNotFound(k8sClient.Get(ctx, key, pod))).To(BeTrue())
+				reconcileOnce()
+				Expect(k8sClient.Get(ctx, key, pod)).To(Succeed())
+				Expect(pod.Status.Phase).To(BeEmpty())
+			}
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			markPodsReady(ctx, k8sClient, resourceName)
+
+			By("Replacing an evicted Pod under Parallel")
+			setPodPhase(1, corev1.PodFailed)
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			expectRecreated(1)
+
+			By("Replacing a completed Pod under OrderedReady before higher ordinals")
+			markPodsReady(ctx, k8sClient, resourceName)
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			setPodPhase(0, corev1.PodSucceeded)
+			reconcileOnce()
+			expectRecreated(0)
+		})
+
+		It("should delete Pods above spec.replicas from the highest ordinal one at a time", func() {
+			By("Reconciling the created resource")
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			By("Scaling the MyStatefulSet down to 1 replica")
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(1)
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			listPodNames := func() []string {
+				podList := &corev1.PodList{}
+				Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+				names := make([]string, 0, len(podList.Items))
+				for _, pod := range podList.Items {
+					names = append(names, pod.Name)
+				}
+				return names
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(listPodNames()).To(ConsistOf(resourceName+"-0", resourceName+"-1"))
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listPodNames()).To(ConsistOf(resourceName + "-0"))
+
+			result, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+
+		It("should delete all Pods above spec.replicas at once under Parallel", func() {
+			By("Reconciling the created resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Scaling the MyStatefulSet down to 1 replica with Parallel policy")
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(1)
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			podList := &corev1.PodList{}
+			Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			Expect(podList.Items).To(HaveLen(1))
+			Expect(podList.Items[0].Name).To(Equal(resourceName + "-0"))
+		})
+
+		It("should populate the status from the observed Pods", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Generation = 2
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			By("Reconciling the created resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.Replicas).To(Equal(int32(3)))
+			Expect(mystatefulset.Status.ReadyReplicas).To(BeZero())
+			Expect(mystatefulset.Status.UpdatedReplicas).To(Equal(int32(3)))
+			Expect(mystatefulset.Status.CurrentReplicas).To(Equal(int32(3)))
+			Expect(mystatefulset.Status.ObservedGeneration).To(BeZero())
+
+			By("Marking the Pods ready and reconciling")
+			markPodsReady(ctx, k8sClient, resourceName)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.ReadyReplicas).To(Equal(int32(3)))
+			Expect(mystatefulset.Status.ObservedGeneration).To(Equal(int64(2)))
+		})
+
+		It("should maintain Available, Progressing, Degraded and ReconcileError conditions", func() {
+			reconcileAndGet := func() *appsv1.MyStatefulSet {
+				_, _ = controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				mystatefulset := &appsv1.MyStatefulSet{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+				return mystatefulset
+			}
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			By("Waiting for Pods to become ready")
+			mystatefulset = reconcileAndGet()
+			Expect(meta.IsStatusConditionFalse(mystatefulset.Status.Conditions, appsv1.ConditionAvailable)).To(BeTrue())
+			progressing := meta.FindStatusCondition(mystatefulset.Status.Conditions, appsv1.ConditionProgressing)
+			Expect(progressing).NotTo(BeNil())
+			Expect(progressing.Status).To(Equal(metav1.ConditionTrue))
+			Expect(progressing.Reason).To(Equal("WaitingForReady"))
+			Expect(meta.IsStatusConditionFalse(mystatefulset.Status.Conditions, appsv1.ConditionDegraded)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(mystatefulset.Status.Conditions, appsv1.ConditionReconcileError)).To(BeTrue())
+
+			By("Becoming available once all Pods are ready")
+			markPodsReady(ctx, k8sClient, resourceName)
+			mystatefulset = reconcileAndGet()
+			Expect(meta.IsStatusConditionTrue(mystatefulset.Status.Conditions, appsv1.ConditionAvailable)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(mystatefulset.Status.Conditions, appsv1.ConditionProgressing)).To(BeTrue())
+
+			By("Reporting a rolling update as progressing")
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			mystatefulset = reconcileAndGet()
+			progressing = meta.FindStatusCondition(mystatefulset.Status.Conditions, appsv1.ConditionProgressing)
+			Expect(progressing.Reason).To(Equal("RollingUpdate"))
+
+			By("Reporting crash-looping Pods as degraded")
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:  "nginx",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+			mystatefulset = reconcileAndGet()
+			degraded := meta.FindStatusCondition(mystatefulset.Status.Conditions, appsv1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal("PodCrashLooping"))
+			Expect(degraded.Message).To(ContainSubstring(resourceName + "-0"))
+
+			By("Recording the last reconcile error")
+			mystatefulset.Spec.Replicas = nil
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			mystatefulset = reconcileAndGet()
+			reconcileError := meta.FindStatusCondition(mystatefulset.Status.Conditions, appsv1.ConditionReconcileError)
+			Expect(reconcileError.Status).To(Equal(metav1.ConditionTrue))
+			Expect(reconcileError.Message).To(Equal("配置缺少期望的副本数量"))
+		})
+
+		It("should record Events for lifecycle actions", func() {
+			drainEvents := func() []string {
+				var events []string
+				for {
+					select {
+					case e := <-recorder.Events:
+						events = append(events, e)
+					default:
+						return events
+					}
+				}
+			}
+			reconcileOnce := func() {
+				_, _ = controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+			}
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			By("Creating the first Pod and its PVC while scaling up")
+			reconcileOnce()
+			events := drainEvents()
+			Expect(events).To(ContainElement("Normal SuccessfulCreatePVC 创建 PVC data-" + resourceName + "-0"))
+			Expect(events).To(ContainElement("Normal SuccessfulCreatePod 创建 Pod " + resourceName + "-0"))
+			Expect(events).To(ContainElement(HavePrefix("Normal ScalingUp ")))
+
+			By("Starting and completing a rollout")
+			for i := 0; i < 3; i++ {
+				markPodsReady(ctx, k8sClient, resourceName)
+				reconcileOnce()
+			}
+			markPodsReady(ctx, k8sClient, resourceName)
+			reconcileOnce()
+			drainEvents()
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(1)
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			events = drainEvents()
+			Expect(events).To(ContainElement(HavePrefix("Normal RolloutStarted ")))
+			Expect(events).To(ContainElement("Normal SuccessfulDeletePod 删除 Pod " + resourceName + "-2"))
+			for i := 0; i < 4; i++ {
+				reconcileOnce()
+				markPodsReady(ctx, k8sClient, resourceName)
+			}
+			reconcileOnce()
+			Expect(drainEvents()).To(ContainElement(HavePrefix("Normal RolloutCompleted ")))
+
+			By("Warning about an invalid spec")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = nil
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			Expect(drainEvents()).To(ContainElement("Warning InvalidSpec 配置缺少期望的副本数量"))
+
+			By("Warning about an invalid maxUnavailable during a rollout")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			maxUnavailable := intstr.FromString("abc")
+			mystatefulset.Spec.Replicas = int32Ptr(1)
+			mystatefulset.Spec.UpdateStrategy.RollingUpdate = &k8sappsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: &maxUnavailable}
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.20"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			Expect(drainEvents()).To(ContainElement(HavePrefix("Warning InvalidSpec 无效的 maxUnavailable: ")))
+
+			By("Warning about an invalid selector")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "app", Operator: "Bogus", Values: []string{resourceName}},
+			}}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			Expect(drainEvents()).To(ContainElement(HavePrefix("Warning InvalidSpec 无效的选择器: ")))
+		})
+
+		It("should not advance observedGeneration while Pods are still being created", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Generation = 2
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.Replicas).To(Equal(int32(1)))
+			Expect(mystatefulset.Status.ObservedGeneration).To(BeZero())
+		})
+
+		It("should record Pod templates as ControllerRevisions and label Pods with their revision", func() {
+			By("Reconciling the created resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.UpdateRevision).NotTo(BeEmpty())
+			Expect(mystatefulset.Status.CurrentRevision).To(Equal(mystatefulset.Status.UpdateRevision))
+
+			revisionList := &k8sappsv1.ControllerRevisionList{}
+			Expect(k8sClient.List(ctx, revisionList, client.InNamespace("default"))).To(Succeed())
+			Expect(revisionList.Items).To(HaveLen(1))
+			Expect(revisionList.Items[0].Name).To(Equal(mystatefulset.Status.UpdateRevision))
+			Expect(revisionList.Items[0].Revision).To(Equal(int64(1)))
+
+			podList := &corev1.PodList{}
+			Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": resourceName})).To(Succeed())
+			for _, pod := range podList.Items {
+				Expect(pod.Labels).To(HaveKeyWithValue(k8sappsv1.ControllerRevisionHashLabelKey, mystatefulset.Status.UpdateRevision))
+			}
+		})
+
+		It("should prune revisions beyond revisionHistoryLimit", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(0)
+			mystatefulset.Spec.RevisionHistoryLimit = int32Ptr(1)
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			for _, image := range []string{"nginx:1.19", "nginx:1.20", "nginx:1.21"} {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+				mystatefulset.Spec.Template.Spec.Containers[0].Image = image
+				Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			}
+			// 第一次调谐完成滚动更新，第二次调谐时旧的当前版本才会进入历史
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			revisionList := &k8sappsv1.ControllerRevisionList{}
+			Expect(k8sClient.List(ctx, revisionList, client.InNamespace("default"))).To(Succeed())
+			revisionNumbers := make([]int64, 0, len(revisionList.Items))
+			for _, revision := range revisionList.Items {
+				revisionNumbers = append(revisionNumbers, revision.Revision)
+			}
+			Expect(revisionNumbers).To(ConsistOf(int64(3), int64(4)))
+		})
+
+		It("should bump collisionCount when a revision name is taken by different data", func() {
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+
+			By("Creating a conflicting ControllerRevision with the expected name")
+			expected, err := newRevision(mystatefulset, 1, 0)
+			Expect(err).NotTo(HaveOccurred())
+			conflicting := expected.DeepCopy()
+			conflicting.Data.Raw = []byte(`{"spec":{"template":{}}}`)
+			Expect(k8sClient.Create(ctx, conflicting)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.CollisionCount).NotTo(BeNil())
+			Expect(*mystatefulset.Status.CollisionCount).To(Equal(int32(1)))
+			Expect(mystatefulset.Status.UpdateRevision).NotTo(Equal(expected.Name))
+		})
+
+		It("should roll the template back to a previous revision", func() {
+			reconcileOnce := func() {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = int32Ptr(0)
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+
+			By("Rolling out a bad image")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:broken"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+
+			By("Requesting a rollback to the previous revision")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.RollbackTo = &appsv1.RollbackConfig{}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Spec.RollbackTo).To(BeNil())
+			Expect(mystatefulset.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:latest"))
+
+			By("Reconciling the restored template")
+			reconcileOnce()
+			revisionList := &k8sappsv1.ControllerRevisionList{}
+			Expect(k8sClient.List(ctx, revisionList, client.InNamespace("default"))).To(Succeed())
+			Expect(revisionList.Items).To(HaveLen(2))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			for _, revision := range revisionList.Items {
+				if revision.Name == mystatefulset.Status.UpdateRevision {
+					Expect(revision.Revision).To(Equal(int64(3)))
+				}
+			}
+
+			By("Ignoring a rollback to an unknown revision")
+			mystatefulset.Spec.RollbackTo = &appsv1.RollbackConfig{Revision: 42}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+			reconcileOnce()
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Spec.RollbackTo).To(BeNil())
+			Expect(mystatefulset.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:latest"))
+			Expect(recorder.Events).To(Receive(Equal("Warning InvalidSpec 未找到要回滚的修订版本 #42，已忽略回滚请求")))
+		})
+
+		It("should undo a bad rollout under OrderedReady while the updated Pod is not ready", func() {
+			reconcileOnce := func() {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			podImage := func(ordinal int) string {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}, pod)).To(Succeed())
+				return pod.Spec.Containers[0].Image
+			}
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			for i := 0; i < 3; i++ {
+				reconcileOnce()
+				markPodsReady(ctx, k8sClient, resourceName)
+			}
+
+			By("Rolling out a bad image that never becomes ready")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:broken"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			reconcileOnce()
+			Expect(podImage(2)).To(Equal("nginx:broken"))
+
+			By("Rolling back while the updated Pod is still not ready")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.RollbackTo = &appsv1.RollbackConfig{}
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			for i := 0; i < 3; i++ {
+				reconcileOnce()
+			}
+			Expect(podImage(2)).To(Equal("nginx:latest"))
+			Expect(podImage(1)).To(Equal("nginx:latest"))
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+		})
+
+		It("should only update Pods at or above the RollingUpdate partition", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			By("Updating the image with a partition of 2")
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.UpdateStrategy = k8sappsv1.StatefulSetUpdateStrategy{
+				Type:          k8sappsv1.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &k8sappsv1.RollingUpdateStatefulSetStrategy{Partition: int32Ptr(2)},
+			}
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			podImage := func(ordinal int) string {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}, pod)).To(Succeed())
+				return pod.Spec.Containers[0].Image
+			}
+			Expect(podImage(2)).To(Equal("nginx:1.19"))
+			Expect(podImage(1)).To(Equal("nginx:latest"))
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+
+			By("Recreating a deleted Pod below the partition with the current revision")
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-0", Namespace: "default"}, pod)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.UpdatedReplicas).To(Equal(int32(1)))
+			Expect(mystatefulset.Status.CurrentReplicas).To(Equal(int32(2)))
+		})
+
+		It("should delete an outdated Pod and recreate it on a later reconcile", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			By("Deleting the highest ordinal without blocking")
+			start := time.Now()
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			pod := &corev1.Pod{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.RollingUpdate).NotTo(BeNil())
+			Expect(mystatefulset.Status.RollingUpdate.Ordinals).To(ConsistOf(int32(2)))
+
+			By("Recreating the Pod with the update revision")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-2", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Spec.Containers[0].Image).To(Equal("nginx:1.19"))
+
+			By("Waiting for the recreated Pod to become ready before moving on")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(pod.Spec.Containers[0].Image).To(Equal("nginx:latest"))
+		})
+
+		It("should replace up to maxUnavailable Pods at a time", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			By("Updating the image with maxUnavailable of 2 under Parallel")
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			maxUnavailable := intstr.FromInt32(2)
+			mystatefulset.Spec.UpdateStrategy = k8sappsv1.StatefulSetUpdateStrategy{
+				Type:          k8sappsv1.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &k8sappsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: &maxUnavailable},
+			}
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			podImage := func(ordinal int) string {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}, pod)).To(Succeed())
+				return pod.Spec.Containers[0].Image
+			}
+
+			By("Deleting the two highest ordinals and recreating them on the next reconcile")
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(podImage(2)).To(Equal("nginx:1.19"))
+			Expect(podImage(1)).To(Equal("nginx:1.19"))
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			Expect(mystatefulset.Status.RollingUpdate).NotTo(BeNil())
+			Expect(mystatefulset.Status.RollingUpdate.Revision).To(Equal(mystatefulset.Status.UpdateRevision))
+			Expect(mystatefulset.Status.RollingUpdate.Ordinals).To(ConsistOf(int32(1), int32(2)))
+
+			By("Waiting while the replaced Pods are not ready")
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+		})
+
+		It("should replace an outdated Pod that is already unavailable without using up maxUnavailable", func() {
+			reconcileOnce := func() {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			podImage := func(ordinal int) string {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}, pod)).To(Succeed())
+				return pod.Spec.Containers[0].Image
+			}
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			markPodsReady(ctx, k8sClient, resourceName)
+
+			By("Rolling out a bad image to the highest ordinal under Parallel")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:broken"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			reconcileOnce()
+			Expect(podImage(2)).To(Equal("nginx:broken"))
+
+			By("Fixing the template while the bad Pod is not ready")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			reconcileOnce()
+			reconcileOnce()
+			Expect(podImage(2)).To(Equal("nginx:1.19"))
+
+			By("Keeping the ready Pods until the replacement becomes ready")
+			Expect(podImage(1)).To(Equal("nginx:latest"))
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+		})
+
+		It("should only replace Pods deleted externally under OnDelete", func() {
+			createReadyPods(ctx, controllerReconciler, k8sClient, typeNamespacedName, 3)
+
+			By("Updating the image with the OnDelete strategy")
+			mystatefulset := &appsv1.MyStatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.UpdateStrategy = k8sappsv1.StatefulSetUpdateStrategy{
+				Type: k8sappsv1.OnDeleteStatefulSetStrategyType,
+			}
+			mystatefulset.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			podImage := func(ordinal int) string {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s-%d", resourceName, ordinal), Namespace: "default"}, pod)).To(Succeed())
+				return pod.Spec.Containers[0].Image
+			}
+			for i := 0; i < 3; i++ {
+				Expect(podImage(i)).To(Equal("nginx:latest"))
+			}
+
+			By("Deleting a Pod by hand")
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-1", Namespace: "default"}, pod)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(podImage(0)).To(Equal("nginx:latest"))
+			Expect(podImage(1)).To(Equal("nginx:1.19"))
+			Expect(podImage(2)).To(Equal("nginx:latest"))
+		})
+
+		It("other", func() {
+			By("Not Found")
+			mystatefulset := &appsv1.MyStatefulSet{}
+			err := k8sClient.Get(ctx, typeNamespacedName, mystatefulset)
+			Expect(err).NotTo(HaveOccurred())
+			mystatefulset.Spec.Replicas = int32Ptr(1)
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, mystatefulset)).To(Succeed())
+			mystatefulset.Spec.Replicas = nil
+			Expect(k8sClient.Update(ctx, mystatefulset)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Eventually(err.Error()).Should(Equal("配置缺少期望的副本数量"))
+
+			Expect(k8sClient.Delete(ctx, mystatefulset)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When filtering owned object events", func() {
+		It("should only pass Pod updates that affect reconciliation", func() {
+			oldPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod-0", ResourceVersion: "1"},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+
+			By("Ignoring a resourceVersion-only change")
+			newPod := oldPod.DeepCopy()
+			newPod.ResourceVersion = "2"
+			Expect(podChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod})).To(BeFalse())
+
+			By("Passing a readiness change")
+			newPod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(podChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod})).To(BeTrue())
+
+			By("Passing a container entering CrashLoopBackOff")
+			oldPod = newPod.DeepCopy()
+			oldPod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "nginx", RestartCount: 1, State: corev1.ContainerState{
+				Running: &corev1.ContainerStateRunning{},
+			}}}
+			newPod = oldPod.DeepCopy()
+			newPod.Status.ContainerStatuses[0].State = corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+			Expect(podChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod})).To(BeTrue())
+
+			By("Passing a container restart")
+			oldPod = newPod.DeepCopy()
+			newPod.Status.ContainerStatuses[0].RestartCount = 2
+			Expect(podChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod})).To(BeTrue())
+
+			By("Ignoring an unchanged container status")
+			oldPod = newPod.DeepCopy()
+			newPod.ResourceVersion = "3"
+			Expect(podChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod})).To(BeFalse())
+
+			By("Passing a deletion")
+			Expect(podChangedPredicate.Delete(event.DeleteEvent{Object: oldPod})).To(BeTrue())
+		})
+
+		It("should only pass PVC updates that affect reconciliation", func() {
+			oldPVC := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data-0"},
+				Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+			}
+
+			newPVC := oldPVC.DeepCopy()
+			newPVC.Annotations = map[string]string{"note": "changed"}
+			Expect(pvcChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPVC, ObjectNew: newPVC})).To(BeFalse())
+
+			newPVC.Status.Phase = corev1.ClaimBound
+			Expect(pvcChangedPredicate.Update(event.UpdateEvent{ObjectOld: oldPVC, ObjectNew: newPVC})).To(BeTrue())
+		})
+	})
+})
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// createReadyPods 在默认的 OrderedReady 策略下逐个创建 MyStatefulSet 的 Pod，每创建一个就将其标记为就绪
+func createReadyPods(ctx context.Context, r *MyStatefulSetReconciler, k8sClient client.Client, key types.NamespacedName, replicas int) {
+	for i := 0; i < replicas; i++ {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		markPodsReady(ctx, k8sClient, key.Name)
+	}
+}
+
+// markPodsReady 将 MyStatefulSet 的所有 Pod 标记为 Running 且 Ready
+func markPodsReady(ctx context.Context, k8sClient client.Client, name string) {
+	podList := &corev1.PodList{}
+	Expect(k8sClient.List(ctx, podList, client.InNamespace("default"), client.MatchingLabels{"mystatefulset-name": name})).To(Succeed())
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+	}
 }
@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	appsv1 "my.com/devops-golang-test/api/v1"
+)
+
+// 事件的 Reason，告警规则可以按这些固定值匹配
+const (
+	eventReasonSuccessfulCreatePod = "SuccessfulCreatePod"
+	eventReasonFailedCreatePod     = "FailedCreatePod"
+	eventReasonSuccessfulDeletePod = "SuccessfulDeletePod"
+	eventReasonFailedDeletePod     = "FailedDeletePod"
+	eventReasonSuccessfulCreatePVC = "SuccessfulCreatePVC"
+	eventReasonFailedCreatePVC     = "FailedCreatePVC"
+	eventReasonSuccessfulDeletePVC = "SuccessfulDeletePVC"
+	eventReasonFailedDeletePVC     = "FailedDeletePVC"
+	eventReasonRolloutStarted      = "RolloutStarted"
+	eventReasonRolloutCompleted    = "RolloutCompleted"
+	eventReasonScalingUp           = "ScalingUp"
+	eventReasonScalingDown         = "ScalingDown"
+	eventReasonInvalidSpec         = "InvalidSpec"
+	eventReasonFailedSyncService   = "FailedSyncService"
+)
+
+// recordEvent 在 MyStatefulSet 上记录事件，未配置 Recorder 时忽略
+func (r *MyStatefulSetReconciler) recordEvent(myStatefulSet *appsv1.MyStatefulSet, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(myStatefulSet, eventType, reason, messageFmt, args...)
+}
+
+// recordStatusEvents 比较状态更新前后的变化，记录滚动更新开始、完成以及扩缩容事件
+func (r *MyStatefulSetReconciler) recordStatusEvents(myStatefulSet *appsv1.MyStatefulSet, oldStatus, newStatus *appsv1.MyStatefulSetStatus) {
+	if oldStatus.UpdateRevision != "" && newStatus.UpdateRevision != oldStatus.UpdateRevision && newStatus.CurrentRevision != newStatus.UpdateRevision {
+		r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonRolloutStarted,
+			"开始滚动更新: %s -> %s", newStatus.CurrentRevision, newStatus.UpdateRevision)
+	}
+	if oldStatus.CurrentRevision != oldStatus.UpdateRevision && newStatus.CurrentRevision == newStatus.UpdateRevision && oldStatus.UpdateRevision == newStatus.UpdateRevision {
+		r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonRolloutCompleted,
+			"滚动更新完成，当前修订版本为 %s", newStatus.CurrentRevision)
+	}
+
+	oldReason := ""
+	if condition := meta.FindStatusCondition(oldStatus.Conditions, appsv1.ConditionProgressing); condition != nil {
+		oldReason = condition.Reason
+	}
+	condition := meta.FindStatusCondition(newStatus.Conditions, appsv1.ConditionProgressing)
+	if condition == nil || condition.Reason == oldReason {
+		return
+	}
+	switch condition.Reason {
+	case reasonScalingUp:
+		r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonScalingUp, "%s", condition.Message)
+	case reasonScalingDown:
+		r.recordEvent(myStatefulSet, corev1.EventTypeNormal, eventReasonScalingDown, "%s", condition.Message)
+	}
+}
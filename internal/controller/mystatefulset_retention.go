@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	k8sappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// pvcRetentionPolicy 返回 PVC 保留策略，未设置的字段按 Retain 处理
+func pvcRetentionPolicy(myStatefulSet *appsv1.MyStatefulSet) k8sappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy {
+	policy := k8sappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: k8sappsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+		WhenScaled:  k8sappsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+	}
+	if p := myStatefulSet.Spec.PersistentVolumeClaimRetentionPolicy; p != nil {
+		if p.WhenDeleted != "" {
+			policy.WhenDeleted = p.WhenDeleted
+		}
+		if p.WhenScaled != "" {
+			policy.WhenScaled = p.WhenScaled
+		}
+	}
+	return policy
+}
+
+// deletePVCsWhenDeleted 判断删除 MyStatefulSet 时是否应删除 PVC
+func deletePVCsWhenDeleted(myStatefulSet *appsv1.MyStatefulSet) bool {
+	return pvcRetentionPolicy(myStatefulSet).WhenDeleted == k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType
+}
+
+// deletePVCsWhenScaled 判断缩容时是否应删除被移除 Pod 的 PVC
+func deletePVCsWhenScaled(myStatefulSet *appsv1.MyStatefulSet) bool {
+	return pvcRetentionPolicy(myStatefulSet).WhenScaled == k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType
+}
+
+// listPVCs 列出 MyStatefulSet 创建的 PVC
+func (r *MyStatefulSetReconciler) listPVCs(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) (*corev1.PersistentVolumeClaimList, error) {
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(req.Namespace),
+		client.MatchingLabels{"mystatefulset-name": myStatefulSet.Name},
+	}
+	if err := r.List(ctx, pvcList, listOpts...); err != nil {
+		return nil, err
+	}
+	return pvcList, nil
+}
+
+// syncPVCRetention 使 PVC 符合保留策略：
+// WhenDeleted 为 Delete 时 PVC 由 MyStatefulSet 控制，随其一起被垃圾回收，为 Retain 时移除该属主引用；
+// WhenScaled 为 Delete 时删除序号不在期望范围内且对应 Pod 已不存在的 PVC。
+func (r *MyStatefulSetReconciler) syncPVCRetention(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, desiredReplicas int32) error {
+	logger := log.FromContext(ctx)
+
+	pvcList, err := r.listPVCs(ctx, req, myStatefulSet)
+	if err != nil {
+		return err
+	}
+
+	ownedBySet := deletePVCsWhenDeleted(myStatefulSet)
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if pvc.DeletionTimestamp != nil {
+			continue
+		}
+
+		if deletePVCsWhenScaled(myStatefulSet) {
+			podName, ok := pvcPodName(myStatefulSet, pvc.Name)
+			ordinal, _ := getOrdinal(myStatefulSet.Name, podName)
+			if ok && !isOrdinalInRange(myStatefulSet, ordinal, desiredReplicas) && findPod(podName, podList) == nil {
+				logger.Info("缩容后删除 PVC", "pvc", pvc.Name)
+				if err := r.deletePVC(ctx, myStatefulSet, pvc); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		changed := false
+		if ownedBySet && metav1.GetControllerOf(pvc) == nil {
+			pvc.OwnerReferences = append(pvc.OwnerReferences, *metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")))
+			changed = true
+		} else if !ownedBySet {
+			changed = removeOwnerReference(pvc, myStatefulSet)
+		}
+		if changed {
+			if err := r.Update(ctx, pvc); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pvcPodName 根据 PVC 名称 <模板名>-<Pod 名> 解析出所属 Pod 的名称
+func pvcPodName(myStatefulSet *appsv1.MyStatefulSet, pvcName string) (string, bool) {
+	for _, pvcTemplate := range myStatefulSet.Spec.VolumeClaimTemplates {
+		podName, found := strings.CutPrefix(pvcName, pvcTemplate.Name+"-")
+		if !found {
+			continue
+		}
+		if _, ok := getOrdinal(myStatefulSet.Name, podName); ok {
+			return podName, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,415 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/google/go-cmp/cmp"
+	k8sappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultRevisionHistoryLimit 是未设置 RevisionHistoryLimit 时保留的历史修订版本数量
+	defaultRevisionHistoryLimit = 10
+
+	// templateHashLabel 是记录创建 Pod 所用模板哈希的标签
+	templateHashLabel = "mystatefulset-template-hash"
+)
+
+// revisionState 记录一次调谐中使用的修订版本
+type revisionState struct {
+	// current 是尚未完成滚动更新时旧 Pod 所处的修订版本
+	current *k8sappsv1.ControllerRevision
+	// update 是由当前 Spec.Template 生成的修订版本
+	update *k8sappsv1.ControllerRevision
+	// collisionCount 是创建修订版本时遇到的哈希冲突次数
+	collisionCount int32
+}
+
+// revisionTemplate 是保存在 ControllerRevision 中的 Pod 模板快照
+type revisionTemplate struct {
+	Spec revisionTemplateSpec `json:"spec"`
+}
+
+type revisionTemplateSpec struct {
+	Template corev1.PodTemplateSpec `json:"template"`
+	Patch    string                 `json:"$patch"`
+}
+
+// syncRevisions 为当前 Pod 模板找到或创建对应的 ControllerRevision，并返回当前版本和更新版本
+func (r *MyStatefulSetReconciler) syncRevisions(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) (*revisionState, error) {
+	revisions, err := r.listRevisions(ctx, req, myStatefulSet)
+	if err != nil {
+		return nil, err
+	}
+
+	var collisionCount int32
+	if myStatefulSet.Status.CollisionCount != nil {
+		collisionCount = *myStatefulSet.Status.CollisionCount
+	}
+
+	updateRevision, err := newRevision(myStatefulSet, nextRevision(revisions), collisionCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var equalRevisions []*k8sappsv1.ControllerRevision
+	for _, revision := range revisions {
+		if bytes.Equal(revision.Data.Raw, updateRevision.Data.Raw) {
+			equalRevisions = append(equalRevisions, revision)
+		}
+	}
+
+	switch {
+	case len(equalRevisions) > 0 && equalRevisions[len(equalRevisions)-1] == revisions[len(revisions)-1]:
+		// 最新的修订版本与当前模板一致，无需变更
+		updateRevision = revisions[len(revisions)-1]
+	case len(equalRevisions) > 0:
+		// 当前模板与某个历史版本一致（例如回滚），将其提升为最新版本
+		updateRevision, err = r.bumpRevision(ctx, equalRevisions[len(equalRevisions)-1], updateRevision.Revision)
+	default:
+		updateRevision, err = r.createRevision(ctx, myStatefulSet, updateRevision, &collisionCount)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &revisionState{update: updateRevision, collisionCount: collisionCount}
+	for _, revision := range revisions {
+		if revision.Name == myStatefulSet.Status.CurrentRevision {
+			state.current = revision
+			break
+		}
+	}
+	if state.current == nil {
+		state.current = updateRevision
+	}
+	return state, nil
+}
+
+// listRevisions 列出由 MyStatefulSet 控制的 ControllerRevision，按修订号从小到大排序
+func (r *MyStatefulSetReconciler) listRevisions(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) ([]*k8sappsv1.ControllerRevision, error) {
+	revisionList := &k8sappsv1.ControllerRevisionList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(req.Namespace),
+		client.MatchingLabels{"mystatefulset-name": myStatefulSet.Name},
+	}
+	if err := r.List(ctx, revisionList, listOpts...); err != nil {
+		return nil, err
+	}
+
+	var revisions []*k8sappsv1.ControllerRevision
+	for i := range revisionList.Items {
+		if metav1.IsControlledBy(&revisionList.Items[i], myStatefulSet) {
+			revisions = append(revisions, &revisionList.Items[i])
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		if revisions[i].Revision == revisions[j].Revision {
+			return revisions[i].CreationTimestamp.Before(&revisions[j].CreationTimestamp)
+		}
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// createRevision 创建 ControllerRevision。名称发生哈希冲突且内容不同时递增 collisionCount 并重试
+func (r *MyStatefulSetReconciler) createRevision(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, revision *k8sappsv1.ControllerRevision, collisionCount *int32) (*k8sappsv1.ControllerRevision, error) {
+	for {
+		hash := hashRevision(revision, *collisionCount)
+		clone := revision.DeepCopy()
+		clone.Name = revisionName(myStatefulSet.Name, hash)
+		clone.Labels[k8sappsv1.ControllerRevisionHashLabelKey] = hash
+
+		err := r.Create(ctx, clone)
+		if err == nil {
+			log.FromContext(ctx).Info("创建修订版本", "revision", clone.Name, "number", clone.Revision)
+			return clone, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		exists := &k8sappsv1.ControllerRevision{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(clone), exists); err != nil {
+			return nil, err
+		}
+		if metav1.IsControlledBy(exists, myStatefulSet) && bytes.Equal(exists.Data.Raw, clone.Data.Raw) {
+			return exists, nil
+		}
+		*collisionCount++
+	}
+}
+
+// bumpRevision 将已存在的修订版本的修订号更新为 revisionNumber
+func (r *MyStatefulSetReconciler) bumpRevision(ctx context.Context, revision *k8sappsv1.ControllerRevision, revisionNumber int64) (*k8sappsv1.ControllerRevision, error) {
+	clone := revision.DeepCopy()
+	clone.Revision = revisionNumber
+	if err := r.Update(ctx, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// truncateHistory 删除超出 RevisionHistoryLimit 的历史修订版本。
+// 当前版本、更新版本以及仍被 Pod 使用的版本不会被删除。
+func (r *MyStatefulSetReconciler) truncateHistory(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet, state *revisionState) error {
+	podList, err := r.listOwnedPods(ctx, req, myStatefulSet)
+	if err != nil {
+		return err
+	}
+	revisions, err := r.listRevisions(ctx, req, myStatefulSet)
+	if err != nil {
+		return err
+	}
+
+	live := map[string]bool{
+		state.current.Name: true,
+		state.update.Name:  true,
+	}
+	for _, pod := range podList.Items {
+		live[getPodRevision(&pod)] = true
+	}
+
+	var history []*k8sappsv1.ControllerRevision
+	for _, revision := range revisions {
+		if !live[revision.Name] {
+			history = append(history, revision)
+		}
+	}
+
+	limit := defaultRevisionHistoryLimit
+	if myStatefulSet.Spec.RevisionHistoryLimit != nil {
+		limit = int(*myStatefulSet.Spec.RevisionHistoryLimit)
+	}
+	for i := 0; i < len(history)-limit; i++ {
+		log.FromContext(ctx).Info("删除历史修订版本", "revision", history[i].Name)
+		if err := r.Delete(ctx, history[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback 将 Spec.Template 恢复为 Spec.RollbackTo 指定的历史修订版本并清空 RollbackTo。
+// 模板变化后由正常的滚动更新流程替换 Pod；找不到指定的修订版本时记录 InvalidSpec 事件并只清空 RollbackTo。
+func (r *MyStatefulSetReconciler) rollback(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) error {
+	logger := log.FromContext(ctx)
+
+	revisions, err := r.listRevisions(ctx, req, myStatefulSet)
+	if err != nil {
+		return err
+	}
+
+	rollbackTo := myStatefulSet.Spec.RollbackTo
+	target := findRollbackRevision(revisions, rollbackTo)
+	if target == nil {
+		logger.Info("未找到要回滚的修订版本，忽略回滚请求", "revision", rollbackTo.Revision, "revisionName", rollbackTo.RevisionName)
+		r.recordEvent(myStatefulSet, corev1.EventTypeWarning, eventReasonInvalidSpec, "未找到要回滚的修订版本 %s，已忽略回滚请求", describeRollbackTarget(rollbackTo))
+	} else {
+		template, err := revisionPodTemplate(target)
+		if err != nil {
+			return err
+		}
+		logger.Info("回滚到修订版本", "revision", target.Name, "number", target.Revision)
+		myStatefulSet.Spec.Template = *template
+	}
+
+	myStatefulSet.Spec.RollbackTo = nil
+	return r.Update(ctx, myStatefulSet)
+}
+
+// describeRollbackTarget 返回回滚目标的可读描述，用于事件消息
+func describeRollbackTarget(rollbackTo *appsv1.RollbackConfig) string {
+	switch {
+	case rollbackTo.RevisionName != "":
+		return rollbackTo.RevisionName
+	case rollbackTo.Revision != 0:
+		return fmt.Sprintf("#%d", rollbackTo.Revision)
+	default:
+		return "（上一个修订版本）"
+	}
+}
+
+// findRollbackRevision 按名称或修订号查找回滚目标，二者都未指定时返回上一个修订版本
+func findRollbackRevision(revisions []*k8sappsv1.ControllerRevision, rollbackTo *appsv1.RollbackConfig) *k8sappsv1.ControllerRevision {
+	switch {
+	case rollbackTo.RevisionName != "":
+		for _, revision := range revisions {
+			if revision.Name == rollbackTo.RevisionName {
+				return revision
+			}
+		}
+	case rollbackTo.Revision != 0:
+		for _, revision := range revisions {
+			if revision.Revision == rollbackTo.Revision {
+				return revision
+			}
+		}
+	case len(revisions) > 1:
+		return revisions[len(revisions)-2]
+	}
+	return nil
+}
+
+// newRevision 根据 MyStatefulSet 的 Pod 模板生成修订号为 revisionNumber 的 ControllerRevision
+func newRevision(myStatefulSet *appsv1.MyStatefulSet, revisionNumber int64, collisionCount int32) (*k8sappsv1.ControllerRevision, error) {
+	data, err := json.Marshal(revisionTemplate{
+		Spec: revisionTemplateSpec{Template: myStatefulSet.Spec.Template, Patch: "replace"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &k8sappsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   myStatefulSet.Namespace,
+			Labels:      createLabels(myStatefulSet.Spec.Template.Labels, myStatefulSet.Name),
+			Annotations: myStatefulSet.Annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")),
+			},
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: revisionNumber,
+	}
+	hash := hashRevision(revision, collisionCount)
+	revision.Name = revisionName(myStatefulSet.Name, hash)
+	revision.Labels[k8sappsv1.ControllerRevisionHashLabelKey] = hash
+	return revision, nil
+}
+
+// revisionPodTemplate 从修订版本中还原 Pod 模板
+func revisionPodTemplate(revision *k8sappsv1.ControllerRevision) (*corev1.PodTemplateSpec, error) {
+	var data revisionTemplate
+	if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+		return nil, fmt.Errorf("解析修订版本 %s 失败: %w", revision.Name, err)
+	}
+	return &data.Spec.Template, nil
+}
+
+// nextRevision 返回下一个修订号
+func nextRevision(revisions []*k8sappsv1.ControllerRevision) int64 {
+	if len(revisions) == 0 {
+		return 1
+	}
+	return revisions[len(revisions)-1].Revision + 1
+}
+
+// hashRevision 计算修订版本内容与冲突计数的哈希值
+func hashRevision(revision *k8sappsv1.ControllerRevision, collisionCount int32) string {
+	hf := fnv.New32a()
+	_, _ = hf.Write(revision.Data.Raw)
+	if collisionCount > 0 {
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, uint32(collisionCount))
+		_, _ = hf.Write(buf)
+	}
+	return rand.SafeEncodeString(strconv.FormatUint(uint64(hf.Sum32()), 10))
+}
+
+// templateHash 计算完整 Pod 模板的稳定哈希。json 序列化按字段顺序和有序的 map 键输出，结果与对象的构造方式无关
+func templateHash(template *corev1.PodTemplateSpec) string {
+	data, err := json.Marshal(template)
+	if err != nil {
+		// PodTemplateSpec 总能被序列化，出错时返回空哈希使 Pod 被视为需要更新
+		return ""
+	}
+	hf := fnv.New32a()
+	_, _ = hf.Write(data)
+	return rand.SafeEncodeString(strconv.FormatUint(uint64(hf.Sum32()), 10))
+}
+
+// stampLegacyPods 为缺少模板哈希标签的 Pod（由引入该标签之前的控制器创建）补充标签。
+// 按旧版控制器的规则（容器数量、镜像、模板标签和注解）仍与期望模板一致的 Pod 被标记为当前模板的哈希和更新版本，
+// 避免升级控制器后所有存量 Pod 都被视为过期而被重建；不一致的 Pod 保持原样，由滚动更新替换。
+func (r *MyStatefulSetReconciler) stampLegacyPods(ctx context.Context, myStatefulSet *appsv1.MyStatefulSet, podList *corev1.PodList, revisions *revisionState) error {
+	hash := templateHash(&myStatefulSet.Spec.Template)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if _, ok := pod.Labels[templateHashLabel]; ok || pod.DeletionTimestamp != nil || !legacyPodMatchesTemplate(pod, &myStatefulSet.Spec.Template) {
+			continue
+		}
+		log.FromContext(ctx).Info("为存量 Pod 补充模板哈希标签", "pod", pod.Name)
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[templateHashLabel] = hash
+		if getPodRevision(pod) == "" {
+			pod.Labels[k8sappsv1.ControllerRevisionHashLabelKey] = revisions.update.Name
+		}
+		if err := r.Update(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyPodMatchesTemplate 按旧版控制器的比较规则判断 Pod 是否与模板一致
+func legacyPodMatchesTemplate(pod *corev1.Pod, template *corev1.PodTemplateSpec) bool {
+	if len(pod.Spec.Containers) != len(template.Spec.Containers) {
+		return false
+	}
+	for i, container := range pod.Spec.Containers {
+		if container.Image != template.Spec.Containers[i].Image {
+			return false
+		}
+	}
+	for key, value := range template.Labels {
+		if pod.Labels[key] != value {
+			return false
+		}
+	}
+	for key, value := range template.Annotations {
+		if pod.Annotations[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// templateDiff 返回两个 Pod 模板之间的字段级差异
+func templateDiff(current, desired *corev1.PodTemplateSpec) string {
+	return cmp.Diff(current, desired)
+}
+
+// revisionName 返回修订版本的名称 <name>-<hash>
+func revisionName(name, hash string) string {
+	return fmt.Sprintf("%s-%s", name, hash)
+}
+
+// getPodRevision 返回 Pod 所处的修订版本名称
+func getPodRevision(pod *corev1.Pod) string {
+	return pod.Labels[k8sappsv1.ControllerRevisionHashLabelKey]
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// syncService 在 ManageService 开启时创建或更新名为 ServiceName 的 Headless Service。
+// 已存在但不受当前 MyStatefulSet 控制的同名 Service 不会被修改。
+func (r *MyStatefulSetReconciler) syncService(ctx context.Context, req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) error {
+	if !myStatefulSet.Spec.ManageService || myStatefulSet.Spec.ServiceName == "" {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	desired := newHeadlessService(req, myStatefulSet)
+	service := &corev1.Service{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: desired.Name}, service)
+	if apierrors.IsNotFound(err) {
+		logger.Info("创建 Headless Service", "service", desired.Name)
+		if err := r.Create(ctx, desired); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(service, myStatefulSet) {
+		logger.Info("同名 Service 不受当前 MyStatefulSet 管理，跳过同步", "service", service.Name)
+		return nil
+	}
+	if equality.Semantic.DeepEqual(service.Spec.Ports, desired.Spec.Ports) &&
+		equality.Semantic.DeepEqual(service.Spec.Selector, desired.Spec.Selector) &&
+		service.Spec.PublishNotReadyAddresses == desired.Spec.PublishNotReadyAddresses {
+		return nil
+	}
+	logger.Info("更新 Headless Service", "service", service.Name)
+	service.Spec.Ports = desired.Spec.Ports
+	service.Spec.Selector = desired.Spec.Selector
+	service.Spec.PublishNotReadyAddresses = desired.Spec.PublishNotReadyAddresses
+	return r.Update(ctx, service)
+}
+
+// newHeadlessService 根据 Pod 模板中声明的容器端口构造 Headless Service
+func newHeadlessService(req ctrl.Request, myStatefulSet *appsv1.MyStatefulSet) *corev1.Service {
+	var ports []corev1.ServicePort
+	// Service 不允许重复的端口和协议组合，多个容器声明同一端口时只保留第一个
+	seen := map[corev1.ServicePort]bool{}
+	for _, container := range myStatefulSet.Spec.Template.Spec.Containers {
+		for _, port := range container.Ports {
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			key := corev1.ServicePort{Protocol: protocol, Port: port.ContainerPort}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			// 存在多个端口时 Service 要求每个端口都有名称，名称中包含协议以区分同一端口的 TCP 和 UDP
+			name := port.Name
+			if name == "" {
+				name = fmt.Sprintf("port-%s-%d", strings.ToLower(string(protocol)), port.ContainerPort)
+			}
+			ports = append(ports, corev1.ServicePort{
+				Name:       name,
+				Protocol:   protocol,
+				Port:       port.ContainerPort,
+				TargetPort: intstr.FromInt32(port.ContainerPort),
+			})
+		}
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      myStatefulSet.Spec.ServiceName,
+			Namespace: req.Namespace,
+			Labels:    createLabels(nil, myStatefulSet.Name),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(myStatefulSet, appsv1.GroupVersion.WithKind("MyStatefulSet")),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  createLabels(nil, myStatefulSet.Name),
+			Ports:     ports,
+			// 允许对等节点在 Pod 就绪前通过 DNS 互相发现
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	k8sappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	defaultRevisionHistoryLimit          = int32(10)
+	defaultTerminationGracePeriodSeconds = int64(30)
+)
+
+// setDefaults fills in every field that upstream StatefulSet defaults, so that stored objects are fully specified
+func setDefaults(mystatefulset *appsv1.MyStatefulSet) {
+	spec := &mystatefulset.Spec
+
+	if spec.Replicas == nil {
+		defaultReplicas := int32(1)
+		spec.Replicas = &defaultReplicas
+		mystatefulsetlog.Info("Setting default replicas", "replicas", defaultReplicas)
+	}
+	if spec.PodManagementPolicy == "" {
+		spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+	}
+	if spec.UpdateStrategy.Type == "" {
+		spec.UpdateStrategy.Type = k8sappsv1.RollingUpdateStatefulSetStrategyType
+	}
+	if spec.UpdateStrategy.Type == k8sappsv1.RollingUpdateStatefulSetStrategyType {
+		if spec.UpdateStrategy.RollingUpdate == nil {
+			spec.UpdateStrategy.RollingUpdate = &k8sappsv1.RollingUpdateStatefulSetStrategy{}
+		}
+		if spec.UpdateStrategy.RollingUpdate.Partition == nil {
+			partition := int32(0)
+			spec.UpdateStrategy.RollingUpdate.Partition = &partition
+		}
+	}
+	if spec.RevisionHistoryLimit == nil {
+		revisionHistoryLimit := defaultRevisionHistoryLimit
+		spec.RevisionHistoryLimit = &revisionHistoryLimit
+	}
+	if spec.Selector == nil && len(spec.Template.Labels) > 0 {
+		matchLabels := make(map[string]string, len(spec.Template.Labels))
+		for key, value := range spec.Template.Labels {
+			matchLabels[key] = value
+		}
+		spec.Selector = &metav1.LabelSelector{MatchLabels: matchLabels}
+	}
+	if spec.PersistentVolumeClaimRetentionPolicy == nil {
+		spec.PersistentVolumeClaimRetentionPolicy = &k8sappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{}
+	}
+	if spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted == "" {
+		spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted = k8sappsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+	if spec.PersistentVolumeClaimRetentionPolicy.WhenScaled == "" {
+		spec.PersistentVolumeClaimRetentionPolicy.WhenScaled = k8sappsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+
+	for i := range spec.VolumeClaimTemplates {
+		setPVCDefaults(&spec.VolumeClaimTemplates[i].Spec)
+	}
+	setPodSpecDefaults(&spec.Template.Spec)
+}
+
+// setUpdateDefaults defaults an updated object against the stored one. An unset selector keeps the stored
+// selector, so sets stored without one stay on the controller's fallback selector instead of being pinned
+// to the template labels carried by the update. A template that differs from the stored one only by
+// defaults is replaced by the stored template, so that defaulting alone never changes the pod template
+// hash and rolls every pod.
+func setUpdateDefaults(mystatefulset, old *appsv1.MyStatefulSet) {
+	selectorUnset := mystatefulset.Spec.Selector == nil
+	setDefaults(mystatefulset)
+	if selectorUnset {
+		mystatefulset.Spec.Selector = old.Spec.Selector.DeepCopy()
+	}
+
+	oldTemplate := old.Spec.Template.DeepCopy()
+	setPodSpecDefaults(&oldTemplate.Spec)
+	if apiequality.Semantic.DeepEqual(mystatefulset.Spec.Template, *oldTemplate) {
+		mystatefulset.Spec.Template = *old.Spec.Template.DeepCopy()
+	}
+}
+
+// storedMyStatefulSet returns the stored object of an update request, or nil for any other request
+func storedMyStatefulSet(ctx context.Context) (*appsv1.MyStatefulSet, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil || req.Operation != admissionv1.Update || len(req.OldObject.Raw) == 0 {
+		return nil, nil
+	}
+	old := &appsv1.MyStatefulSet{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return nil, fmt.Errorf("failed to decode the stored MyStatefulSet: %w", err)
+	}
+	return old, nil
+}
+
+// setPVCDefaults defaults the volume mode and access modes of a volume claim template
+func setPVCDefaults(pvcSpec *corev1.PersistentVolumeClaimSpec) {
+	if pvcSpec.VolumeMode == nil {
+		volumeMode := corev1.PersistentVolumeFilesystem
+		pvcSpec.VolumeMode = &volumeMode
+	}
+	if len(pvcSpec.AccessModes) == 0 {
+		pvcSpec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+}
+
+// setPodSpecDefaults applies the defaults the API server would otherwise add to every pod created from the template
+func setPodSpecDefaults(podSpec *corev1.PodSpec) {
+	if podSpec.TerminationGracePeriodSeconds == nil {
+		terminationGracePeriodSeconds := defaultTerminationGracePeriodSeconds
+		podSpec.TerminationGracePeriodSeconds = &terminationGracePeriodSeconds
+	}
+	if podSpec.RestartPolicy == "" {
+		podSpec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if podSpec.DNSPolicy == "" {
+		podSpec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if podSpec.SchedulerName == "" {
+		podSpec.SchedulerName = corev1.DefaultSchedulerName
+	}
+	if podSpec.SecurityContext == nil {
+		podSpec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	for i := range podSpec.InitContainers {
+		setContainerDefaults(&podSpec.InitContainers[i])
+	}
+	for i := range podSpec.Containers {
+		setContainerDefaults(&podSpec.Containers[i])
+	}
+}
+
+// setContainerDefaults defaults the image pull policy, termination message settings and port protocols of a container
+func setContainerDefaults(container *corev1.Container) {
+	if container.ImagePullPolicy == "" {
+		if usesLatestTag(container.Image) {
+			container.ImagePullPolicy = corev1.PullAlways
+		} else {
+			container.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+	}
+	if container.TerminationMessagePath == "" {
+		container.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	}
+	if container.TerminationMessagePolicy == "" {
+		container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+	for i := range container.Ports {
+		if container.Ports[i].Protocol == "" {
+			container.Ports[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// deletionProtectionAnnotation marks a MyStatefulSet whose deletion must be confirmed
+	deletionProtectionAnnotation = "apps.my.com/deletion-protection"
+	// confirmDeletionAnnotation confirms a deletion when its value equals the MyStatefulSet name
+	confirmDeletionAnnotation = "apps.my.com/confirm-deletion"
+)
+
+// validateDeletion denies deleting a protected MyStatefulSet unless the deletion has been confirmed.
+// Protection is opt-in through the annotation or the cluster-wide policy, so that unprotected sets
+// can still be removed by garbage collection and namespace deletion; the denial reports how many
+// pods are still Ready.
+func (v *MyStatefulSetCustomValidator) validateDeletion(ctx context.Context, mystatefulset *appsv1.MyStatefulSet) error {
+	if deletionConfirmed(mystatefulset) {
+		return nil
+	}
+	if !v.ProtectAll && mystatefulset.Annotations[deletionProtectionAnnotation] != "true" {
+		return nil
+	}
+
+	reason := "it is protected from deletion"
+	if v.Reader != nil {
+		ready, err := v.readyPods(ctx, mystatefulset)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		if ready > 0 {
+			reason = fmt.Sprintf("%s and %d of its pods are still Ready", reason, ready)
+		}
+	}
+
+	return apierrors.NewForbidden(appsv1.GroupVersion.WithResource("mystatefulsets").GroupResource(), mystatefulset.Name,
+		fmt.Errorf("%s; set the %s annotation to %q to confirm the deletion", reason, confirmDeletionAnnotation, mystatefulset.Name))
+}
+
+// deletionConfirmed reports whether the confirmation annotation names the MyStatefulSet
+func deletionConfirmed(mystatefulset *appsv1.MyStatefulSet) bool {
+	confirmed, ok := mystatefulset.Annotations[confirmDeletionAnnotation]
+	return ok && confirmed == mystatefulset.Name
+}
+
+// readyPods counts the Ready pods controlled by the MyStatefulSet
+func (v *MyStatefulSetCustomValidator) readyPods(ctx context.Context, mystatefulset *appsv1.MyStatefulSet) (int, error) {
+	podList := &corev1.PodList{}
+	if err := v.Reader.List(ctx, podList,
+		client.InNamespace(mystatefulset.Namespace),
+		client.MatchingLabels{"mystatefulset-name": mystatefulset.Name},
+	); err != nil {
+		return 0, err
+	}
+
+	ready := 0
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if owner := metav1.GetControllerOf(pod); owner == nil || owner.UID != mystatefulset.UID {
+			continue
+		}
+		if pod.DeletionTimestamp == nil && isPodReady(pod) {
+			ready++
+		}
+	}
+	return ready, nil
+}
+
+// isPodReady reports whether the pod's Ready condition is True
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	k8sappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	appsv1 "my.com/devops-golang-test/api/v1"
+)
+
+// validateMyStatefulSet performs the structural validation shared by creates and updates
+func validateMyStatefulSet(mystatefulset *appsv1.MyStatefulSet) field.ErrorList {
+	spec := &mystatefulset.Spec
+	specPath := field.NewPath("spec")
+
+	var allErrs field.ErrorList
+	if spec.Replicas != nil && *spec.Replicas < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *spec.Replicas, "replicas must be greater than or equal to 1"))
+	}
+	allErrs = append(allErrs, validateOrdinals(spec, specPath)...)
+	allErrs = append(allErrs, validateSelector(spec, specPath)...)
+	allErrs = append(allErrs, validateServiceName(spec, specPath)...)
+	allErrs = append(allErrs, validateNameLength(mystatefulset)...)
+	allErrs = append(allErrs, validateVolumeClaimTemplates(spec, specPath)...)
+	allErrs = append(allErrs, validateUpdateStrategy(spec, specPath)...)
+	allErrs = append(allErrs, validatePodManagementPolicy(spec, specPath)...)
+	if len(spec.Template.Spec.Containers) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("template", "spec", "containers"), "at least one container is required"))
+	}
+	return allErrs
+}
+
+// validateOrdinals ensures the ordinal range starts at a non-negative ordinal
+func validateOrdinals(spec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	if spec.Ordinals == nil {
+		return nil
+	}
+	if spec.Ordinals.Start < 0 {
+		return field.ErrorList{field.Invalid(specPath.Child("ordinals", "start"), spec.Ordinals.Start, "must be greater than or equal to 0")}
+	}
+	// the highest ordinal must still fit in an int32 pod index
+	if spec.Replicas != nil && int64(spec.Ordinals.Start)+int64(*spec.Replicas)-1 > math.MaxInt32 {
+		return field.ErrorList{field.Invalid(specPath.Child("ordinals", "start"), spec.Ordinals.Start,
+			fmt.Sprintf("start + replicas - 1 must not exceed %d", math.MaxInt32))}
+	}
+	return nil
+}
+
+// validateSelector ensures the selector is non-empty and selects the pods created from the template
+func validateSelector(spec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	selectorPath := specPath.Child("selector")
+	if spec.Selector == nil || (len(spec.Selector.MatchLabels) == 0 && len(spec.Selector.MatchExpressions) == 0) {
+		return field.ErrorList{field.Required(selectorPath, "a non-empty selector is required")}
+	}
+	selector, err := metav1.LabelSelectorAsSelector(spec.Selector)
+	if err != nil {
+		return field.ErrorList{field.Invalid(selectorPath, spec.Selector, err.Error())}
+	}
+	if !selector.Matches(labels.Set(spec.Template.Labels)) {
+		return field.ErrorList{field.Invalid(specPath.Child("template", "metadata", "labels"), spec.Template.Labels, "`selector` does not match template `labels`")}
+	}
+	return nil
+}
+
+// isSelectorRequiredError matches the error validateSelector reports for a missing selector
+func isSelectorRequiredError(err error) bool {
+	var fieldErr *field.Error
+	return errors.As(err, &fieldErr) && fieldErr.Type == field.ErrorTypeRequired && fieldErr.Field == "spec.selector"
+}
+
+// validateServiceName ensures the governing service name can be used as the pods' subdomain
+func validateServiceName(spec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	serviceNamePath := specPath.Child("serviceName")
+	if spec.ServiceName == "" {
+		return field.ErrorList{field.Required(serviceNamePath, "")}
+	}
+	var allErrs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(spec.ServiceName) {
+		allErrs = append(allErrs, field.Invalid(serviceNamePath, spec.ServiceName, msg))
+	}
+	return allErrs
+}
+
+// revisionSuffixLength is the length of the "-<hash>" suffix appended to the set name to form
+// the controller-revision-hash label value; the hash is at most 10 characters
+const revisionSuffixLength = 11
+
+// validateNameLength ensures the names of the pods and PVCs for the highest ordinal, and the
+// controller-revision-hash label value, stay within the 63 character limit of a DNS label
+func validateNameLength(mystatefulset *appsv1.MyStatefulSet) field.ErrorList {
+	if mystatefulset.Name == "" {
+		return nil
+	}
+	var allErrs field.ErrorList
+	namePath := field.NewPath("metadata", "name")
+	if maxLength := validation.LabelValueMaxLength - revisionSuffixLength; len(mystatefulset.Name) > maxLength {
+		allErrs = append(allErrs, field.Invalid(namePath, mystatefulset.Name,
+			fmt.Sprintf("must be no more than %d characters so that the controller-revision-hash label fits", maxLength)))
+	}
+
+	replicas := int32(1)
+	if mystatefulset.Spec.Replicas != nil && *mystatefulset.Spec.Replicas > 0 {
+		replicas = *mystatefulset.Spec.Replicas
+	}
+	start := int32(0)
+	if mystatefulset.Spec.Ordinals != nil {
+		start = mystatefulset.Spec.Ordinals.Start
+	}
+	podName := fmt.Sprintf("%s-%d", mystatefulset.Name, start+replicas-1)
+	if len(podName) > validation.DNS1123LabelMaxLength {
+		allErrs = append(allErrs, field.Invalid(namePath, mystatefulset.Name,
+			fmt.Sprintf("pod name %q must be no more than %d characters", podName, validation.DNS1123LabelMaxLength)))
+	}
+	for i, pvcTemplate := range mystatefulset.Spec.VolumeClaimTemplates {
+		pvcName := fmt.Sprintf("%s-%s", pvcTemplate.Name, podName)
+		if len(pvcName) > validation.DNS1123LabelMaxLength {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "volumeClaimTemplates").Index(i).Child("metadata", "name"), pvcTemplate.Name,
+				fmt.Sprintf("PVC name %q must be no more than %d characters", pvcName, validation.DNS1123LabelMaxLength)))
+		}
+	}
+	return allErrs
+}
+
+// validateVolumeClaimTemplates ensures every template has a unique name and requests storage
+func validateVolumeClaimTemplates(spec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := sets.New[string]()
+	for i, pvcTemplate := range spec.VolumeClaimTemplates {
+		templatePath := specPath.Child("volumeClaimTemplates").Index(i)
+		namePath := templatePath.Child("metadata", "name")
+		switch {
+		case pvcTemplate.Name == "":
+			allErrs = append(allErrs, field.Required(namePath, ""))
+		case names.Has(pvcTemplate.Name):
+			allErrs = append(allErrs, field.Duplicate(namePath, pvcTemplate.Name))
+		default:
+			names.Insert(pvcTemplate.Name)
+			for _, msg := range validation.IsDNS1123Label(pvcTemplate.Name) {
+				allErrs = append(allErrs, field.Invalid(namePath, pvcTemplate.Name, msg))
+			}
+		}
+		if _, ok := pvcTemplate.Spec.Resources.Requests[corev1.ResourceStorage]; !ok {
+			allErrs = append(allErrs, field.Required(templatePath.Child("spec", "resources", "requests").Key(string(corev1.ResourceStorage)), ""))
+		}
+	}
+	return allErrs
+}
+
+// validateUpdateStrategy ensures the update strategy type is supported and only RollingUpdate carries rolling update parameters
+func validateUpdateStrategy(spec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	strategyPath := specPath.Child("updateStrategy")
+	strategy := spec.UpdateStrategy
+
+	var allErrs field.ErrorList
+	switch strategy.Type {
+	case "", k8sappsv1.RollingUpdateStatefulSetStrategyType:
+		if strategy.RollingUpdate == nil {
+			break
+		}
+		if strategy.RollingUpdate.Partition != nil && *strategy.RollingUpdate.Partition < 0 {
+			allErrs = append(allErrs, field.Invalid(strategyPath.Child("rollingUpdate", "partition"), *strategy.RollingUpdate.Partition, "must be greater than or equal to 0"))
+		}
+		if strategy.RollingUpdate.MaxUnavailable != nil {
+			allErrs = append(allErrs, validateMaxUnavailable(*strategy.RollingUpdate.MaxUnavailable, strategyPath.Child("rollingUpdate", "maxUnavailable"))...)
+		}
+	case k8sappsv1.OnDeleteStatefulSetStrategyType:
+		if strategy.RollingUpdate != nil {
+			allErrs = append(allErrs, field.Forbidden(strategyPath.Child("rollingUpdate"),
+				fmt.Sprintf("only allowed for updateStrategy '%s'", k8sappsv1.RollingUpdateStatefulSetStrategyType)))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(strategyPath.Child("type"), strategy.Type,
+			[]string{string(k8sappsv1.RollingUpdateStatefulSetStrategyType), string(k8sappsv1.OnDeleteStatefulSetStrategyType)}))
+	}
+	return allErrs
+}
+
+// validateMaxUnavailable ensures maxUnavailable is a positive integer or a percentage between 1% and 100%
+func validateMaxUnavailable(maxUnavailable intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	switch maxUnavailable.Type {
+	case intstr.Int:
+		if maxUnavailable.IntVal <= 0 {
+			return field.ErrorList{field.Invalid(fldPath, maxUnavailable.IntVal, "must be greater than 0")}
+		}
+	case intstr.String:
+		if msgs := validation.IsValidPercent(maxUnavailable.StrVal); len(msgs) > 0 {
+			return field.ErrorList{field.Invalid(fldPath, maxUnavailable.StrVal, strings.Join(msgs, "; "))}
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(maxUnavailable.StrVal, "%"))
+		if err != nil {
+			return field.ErrorList{field.Invalid(fldPath, maxUnavailable.StrVal, err.Error())}
+		}
+		if percent <= 0 {
+			return field.ErrorList{field.Invalid(fldPath, maxUnavailable.StrVal, "must be greater than 0%")}
+		}
+		if percent > 100 {
+			return field.ErrorList{field.Invalid(fldPath, maxUnavailable.StrVal, "must not be greater than 100%")}
+		}
+	default:
+		return field.ErrorList{field.Invalid(fldPath, maxUnavailable, "must be an integer or percentage (e.g '5%')")}
+	}
+	return nil
+}
+
+// validatePodManagementPolicy ensures the pod management policy is supported
+func validatePodManagementPolicy(spec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	switch spec.PodManagementPolicy {
+	case "", k8sappsv1.OrderedReadyPodManagement, k8sappsv1.ParallelPodManagement:
+		return nil
+	default:
+		return field.ErrorList{field.NotSupported(specPath.Child("podManagementPolicy"), spec.PodManagementPolicy,
+			[]string{string(k8sappsv1.OrderedReadyPodManagement), string(k8sappsv1.ParallelPodManagement)})}
+	}
+}
+
+// validateImmutableFields rejects changes to the fields that, like upstream StatefulSet,
+// cannot be changed once the set has been created
+func validateImmutableFields(newSpec, oldSpec *appsv1.MyStatefulSetSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if !apiequality.Semantic.DeepEqual(newSpec.Selector, oldSpec.Selector) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("selector"), "field is immutable"))
+	}
+	if newSpec.ServiceName != oldSpec.ServiceName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("serviceName"), "field is immutable"))
+	}
+	if !apiequality.Semantic.DeepEqual(newSpec.VolumeClaimTemplates, oldSpec.VolumeClaimTemplates) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("volumeClaimTemplates"), "field is immutable"))
+	}
+	if podManagementPolicy(newSpec) != podManagementPolicy(oldSpec) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("podManagementPolicy"), "field is immutable"))
+	}
+	return allErrs
+}
+
+// podManagementPolicy returns the effective pod management policy, treating an unset policy as OrderedReady
+func podManagementPolicy(spec *appsv1.MyStatefulSetSpec) k8sappsv1.PodManagementPolicyType {
+	if spec.PodManagementPolicy == "" {
+		return k8sappsv1.OrderedReadyPodManagement
+	}
+	return spec.PodManagementPolicy
+}
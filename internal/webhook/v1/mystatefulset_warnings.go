@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"fmt"
+	"strings"
+
+	k8sappsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	appsv1 "my.com/devops-golang-test/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// warningsForMyStatefulSet returns warnings for specs that are legal but likely to cause trouble
+func warningsForMyStatefulSet(mystatefulset *appsv1.MyStatefulSet) admission.Warnings {
+	spec := &mystatefulset.Spec
+	specPath := field.NewPath("spec")
+	podSpecPath := specPath.Child("template", "spec")
+
+	var warnings admission.Warnings
+	for i, container := range spec.Template.Spec.InitContainers {
+		warnings = append(warnings, warningsForImage(podSpecPath.Child("initContainers").Index(i), container.Image)...)
+	}
+	for i, container := range spec.Template.Spec.Containers {
+		containerPath := podSpecPath.Child("containers").Index(i)
+		warnings = append(warnings, warningsForImage(containerPath, container.Image)...)
+		if podManagementPolicy(spec) == k8sappsv1.OrderedReadyPodManagement && container.ReadinessProbe == nil {
+			warnings = append(warnings, fmt.Sprintf("%s: no readiness probe; under OrderedReady the next pod is created as soon as the container starts",
+				containerPath.Child("readinessProbe")))
+		}
+		if len(container.Resources.Requests) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: no resource requests; the pods may be scheduled onto nodes without enough capacity",
+				containerPath.Child("resources", "requests")))
+		}
+	}
+	if spec.PodManagementPolicy == k8sappsv1.ParallelPodManagement && len(spec.VolumeClaimTemplates) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: Parallel pod management with volumeClaimTemplates starts and stops pods without waiting for their peers, which clustered storage often cannot tolerate",
+			specPath.Child("podManagementPolicy")))
+	}
+	if spec.RevisionHistoryLimit != nil && *spec.RevisionHistoryLimit == 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: 0 keeps no revision history, so the set cannot be rolled back",
+			specPath.Child("revisionHistoryLimit")))
+	}
+	return warnings
+}
+
+// warningsForImage warns about images that use the latest tag, explicitly or implicitly
+func warningsForImage(containerPath *field.Path, image string) admission.Warnings {
+	if image == "" || !usesLatestTag(image) {
+		return nil
+	}
+	return admission.Warnings{fmt.Sprintf("%s: %q uses the latest tag; pods recreated during a rollout or rescheduling may run a different image",
+		containerPath.Child("image"), image)}
+}
+
+// usesLatestTag reports whether the image is not pinned to a digest and has either no tag or the latest tag
+func usesLatestTag(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	tag := ""
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		tag = name[i+1:]
+	}
+	return tag == "" || tag == "latest"
+}
+
+// warningsForScaleDown warns when an update removes ordinals that have persistent volume claims
+func warningsForScaleDown(newObj, oldObj *appsv1.MyStatefulSet) admission.Warnings {
+	if len(newObj.Spec.VolumeClaimTemplates) == 0 || newObj.Spec.Replicas == nil || oldObj.Spec.Replicas == nil {
+		return nil
+	}
+	newReplicas, oldReplicas := *newObj.Spec.Replicas, *oldObj.Spec.Replicas
+	if newReplicas >= oldReplicas {
+		return nil
+	}
+
+	start := int32(0)
+	if newObj.Spec.Ordinals != nil {
+		start = newObj.Spec.Ordinals.Start
+	}
+	first, last := start+newReplicas, start+oldReplicas-1
+	ordinals := fmt.Sprintf("%d", first)
+	if first != last {
+		ordinals = fmt.Sprintf("%d-%d", first, last)
+	}
+
+	replicasPath := field.NewPath("spec", "replicas")
+	policy := newObj.Spec.PersistentVolumeClaimRetentionPolicy
+	if policy != nil && policy.WhenScaled == k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType {
+		return admission.Warnings{fmt.Sprintf("%s: scaling down from %d to %d deletes the pods and persistent volume claims of ordinals %s; their data will be lost",
+			replicasPath, oldReplicas, newReplicas, ordinals)}
+	}
+	return admission.Warnings{fmt.Sprintf("%s: scaling down from %d to %d deletes the pods of ordinals %s; their persistent volume claims are retained",
+		replicasPath, oldReplicas, newReplicas, ordinals)}
+}
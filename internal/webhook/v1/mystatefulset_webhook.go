@@ -3,9 +3,14 @@ package v1
 import (
 	"context"
 	"fmt"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	appsv1 "my.com/devops-golang-test/api/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -14,9 +19,10 @@ import (
 var mystatefulsetlog = logf.Log.WithName("mystatefulset-resource")
 
 // SetupMyStatefulSetWebhookWithManager registers the webhook for MyStatefulSet in the manager.
-func SetupMyStatefulSetWebhookWithManager(mgr ctrl.Manager) error {
+// When protectAll is set, deleting any MyStatefulSet requires confirmation.
+func SetupMyStatefulSetWebhookWithManager(mgr ctrl.Manager, protectAll bool) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&appsv1.MyStatefulSet{}).
-		WithValidator(&MyStatefulSetCustomValidator{}).
+		WithValidator(&MyStatefulSetCustomValidator{Reader: mgr.GetAPIReader(), ProtectAll: protectAll}).
 		WithDefaulter(&MyStatefulSetCustomDefaulter{}).
 		Complete()
 }
@@ -34,18 +40,26 @@ func (d *MyStatefulSetCustomDefaulter) Default(ctx context.Context, obj runtime.
 	}
 	mystatefulsetlog.Info("Defaulting MyStatefulSet", "name", mystatefulset.GetName())
 
-	// Example defaulting logic
-	if mystatefulset.Spec.Replicas == nil {
-		defaultReplicas := int32(1)
-		mystatefulset.Spec.Replicas = &defaultReplicas
-		mystatefulsetlog.Info("Setting default replicas", "replicas", defaultReplicas)
+	old, err := storedMyStatefulSet(ctx)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		setUpdateDefaults(mystatefulset, old)
+	} else {
+		setDefaults(mystatefulset)
 	}
 
 	return nil
 }
 
 // MyStatefulSetCustomValidator validates MyStatefulSet
-type MyStatefulSetCustomValidator struct{}
+type MyStatefulSetCustomValidator struct {
+	// Reader looks up the Ready pods of a protected MyStatefulSet being deleted; they are not counted when nil
+	Reader client.Reader
+	// ProtectAll requires confirmation before deleting any MyStatefulSet
+	ProtectAll bool
+}
 
 var _ webhook.CustomValidator = &MyStatefulSetCustomValidator{}
 
@@ -57,12 +71,12 @@ func (v *MyStatefulSetCustomValidator) ValidateCreate(ctx context.Context, obj r
 	}
 	mystatefulsetlog.Info("Validating MyStatefulSet creation", "name", mystatefulset.GetName())
 
-	// Example validation logic
-	if mystatefulset.Spec.Replicas != nil && *mystatefulset.Spec.Replicas < 1 {
-		return nil, fmt.Errorf("replicas must be greater than or equal to 1")
+	warnings := warningsForMyStatefulSet(mystatefulset)
+	if errs := validateMyStatefulSet(mystatefulset); len(errs) > 0 {
+		return warnings, apierrors.NewInvalid(appsv1.GroupVersion.WithKind("MyStatefulSet").GroupKind(), mystatefulset.Name, errs)
 	}
 
-	return nil, nil
+	return warnings, nil
 }
 
 // ValidateUpdate validates MyStatefulSet upon update
@@ -71,14 +85,40 @@ func (v *MyStatefulSetCustomValidator) ValidateUpdate(ctx context.Context, oldOb
 	if !ok {
 		return nil, fmt.Errorf("expected a MyStatefulSet object for the newObj but got %T", newObj)
 	}
+	oldStatefulset, ok := oldObj.(*appsv1.MyStatefulSet)
+	if !ok {
+		return nil, fmt.Errorf("expected a MyStatefulSet object for the oldObj but got %T", oldObj)
+	}
 	mystatefulsetlog.Info("Validating MyStatefulSet update", "name", newStatefulset.GetName())
 
-	// Example validation logic
-	if newStatefulset.Spec.Replicas != nil && *newStatefulset.Spec.Replicas < 1 {
-		return nil, fmt.Errorf("replicas must be greater than or equal to 1")
+	// Objects stored before defaulting was extended lack some defaults, so compare defaulted copies
+	newDefaulted, oldDefaulted := newStatefulset.DeepCopy(), oldStatefulset.DeepCopy()
+	setUpdateDefaults(newDefaulted, oldStatefulset)
+	setUpdateDefaults(oldDefaulted, oldStatefulset)
+	// Sets stored without a selector may adopt one
+	if oldStatefulset.Spec.Selector == nil {
+		oldDefaulted.Spec.Selector = newDefaulted.Spec.Selector
+	}
+
+	// Metadata-only updates, such as the controller adding or removing its finalizer, must succeed
+	// even for objects stored before the current validation rules existed
+	if newStatefulset.DeletionTimestamp != nil || apiequality.Semantic.DeepEqual(newDefaulted.Spec, oldDefaulted.Spec) {
+		return nil, nil
+	}
+
+	warnings := warningsForMyStatefulSet(newStatefulset)
+	warnings = append(warnings, warningsForScaleDown(newStatefulset, oldStatefulset)...)
+	allErrs := validateMyStatefulSet(newStatefulset)
+	if newStatefulset.Spec.Selector == nil && oldStatefulset.Spec.Selector == nil {
+		// Sets stored without a selector keep the controller's fallback selector
+		allErrs = allErrs.Filter(isSelectorRequiredError)
+	}
+	allErrs = append(allErrs, validateImmutableFields(&newDefaulted.Spec, &oldDefaulted.Spec, field.NewPath("spec"))...)
+	if len(allErrs) > 0 {
+		return warnings, apierrors.NewInvalid(appsv1.GroupVersion.WithKind("MyStatefulSet").GroupKind(), newStatefulset.Name, allErrs)
 	}
 
-	return nil, nil
+	return warnings, nil
 }
 
 // ValidateDelete validates MyStatefulSet upon deletion
@@ -89,6 +129,5 @@ func (v *MyStatefulSetCustomValidator) ValidateDelete(ctx context.Context, obj r
 	}
 	mystatefulsetlog.Info("Validating MyStatefulSet deletion", "name", mystatefulset.GetName())
 
-	// No specific validation logic for deletion in this example
-	return nil, nil
+	return nil, v.validateDeletion(ctx, mystatefulset)
 }
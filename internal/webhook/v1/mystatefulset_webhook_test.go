@@ -2,14 +2,27 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
+	admissionv1 "k8s.io/api/admission/v1"
+	k8sappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"math"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	appsv1 "my.com/devops-golang-test/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestAPIs(t *testing.T) {
@@ -37,8 +50,8 @@ var _ = Describe("MyStatefulSet Webhook", func() {
 	)
 
 	BeforeEach(func() {
-		obj = &appsv1.MyStatefulSet{}
-		oldObj = &appsv1.MyStatefulSet{}
+		obj = newValidMyStatefulSet()
+		oldObj = newValidMyStatefulSet()
 		validator = MyStatefulSetCustomValidator{}
 		defaulter = MyStatefulSetCustomDefaulter{}
 		ctx = context.TODO()
@@ -57,6 +70,126 @@ var _ = Describe("MyStatefulSet Webhook", func() {
 		})
 	})
 
+	Context("When defaulting a sparse MyStatefulSet", func() {
+		It("Should fill in every upstream StatefulSet default", func() {
+			obj.Spec.Selector = nil
+			obj.Spec.VolumeClaimTemplates[0].Spec.AccessModes = nil
+			obj.Spec.Template.Spec.Containers = append(obj.Spec.Template.Spec.Containers, corev1.Container{
+				Name:  "sidecar",
+				Image: "busybox",
+				Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+			})
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			spec := obj.Spec
+			Expect(spec.PodManagementPolicy).To(Equal(k8sappsv1.OrderedReadyPodManagement))
+			Expect(spec.UpdateStrategy.Type).To(Equal(k8sappsv1.RollingUpdateStatefulSetStrategyType))
+			Expect(spec.UpdateStrategy.RollingUpdate).ToNot(BeNil())
+			Expect(*spec.UpdateStrategy.RollingUpdate.Partition).To(Equal(int32(0)))
+			Expect(*spec.RevisionHistoryLimit).To(Equal(int32(10)))
+			Expect(spec.Selector).To(Equal(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}))
+			Expect(spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted).To(Equal(k8sappsv1.RetainPersistentVolumeClaimRetentionPolicyType))
+			Expect(spec.PersistentVolumeClaimRetentionPolicy.WhenScaled).To(Equal(k8sappsv1.RetainPersistentVolumeClaimRetentionPolicyType))
+
+			pvcSpec := spec.VolumeClaimTemplates[0].Spec
+			Expect(*pvcSpec.VolumeMode).To(Equal(corev1.PersistentVolumeFilesystem))
+			Expect(pvcSpec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+
+			podSpec := spec.Template.Spec
+			Expect(*podSpec.TerminationGracePeriodSeconds).To(Equal(int64(30)))
+			Expect(podSpec.RestartPolicy).To(Equal(corev1.RestartPolicyAlways))
+			Expect(podSpec.DNSPolicy).To(Equal(corev1.DNSClusterFirst))
+			Expect(podSpec.SchedulerName).To(Equal(corev1.DefaultSchedulerName))
+			Expect(podSpec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+			Expect(podSpec.Containers[0].TerminationMessagePath).To(Equal(corev1.TerminationMessagePathDefault))
+			Expect(podSpec.Containers[1].ImagePullPolicy).To(Equal(corev1.PullAlways))
+			Expect(podSpec.Containers[1].Ports[0].Protocol).To(Equal(corev1.ProtocolTCP))
+		})
+
+		It("Should keep explicitly set values", func() {
+			obj.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			obj.Spec.UpdateStrategy = k8sappsv1.StatefulSetUpdateStrategy{Type: k8sappsv1.OnDeleteStatefulSetStrategyType}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.PodManagementPolicy).To(Equal(k8sappsv1.ParallelPodManagement))
+			Expect(obj.Spec.UpdateStrategy.RollingUpdate).To(BeNil())
+
+			By("producing an object that passes validation")
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should not reject updates to objects stored before the defaults existed", func() {
+			oldObj.Spec.Selector = nil
+			obj = oldObj.DeepCopy()
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should not change the template of a stored set when only other fields are updated", func() {
+			replicas := int32(3)
+			obj.Spec.Replicas = &replicas
+			updateCtx := updateRequestContext(ctx, oldObj)
+			Expect(defaulter.Default(updateCtx, obj)).To(Succeed())
+			Expect(obj.Spec.Template).To(Equal(oldObj.Spec.Template))
+			Expect(obj.Spec.PodManagementPolicy).To(Equal(k8sappsv1.OrderedReadyPodManagement))
+
+			By("defaulting a template that actually changed")
+			obj.Spec.Template.Spec.Containers[0].Image = "nginx:1.28"
+			Expect(defaulter.Default(updateCtx, obj)).To(Succeed())
+			Expect(obj.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		})
+
+		It("Should keep sets stored without a selector on the fallback selector", func() {
+			oldObj.Spec.Selector = nil
+			obj = oldObj.DeepCopy()
+			obj.Spec.Template.Labels = map[string]string{"app": "renamed"}
+			updateCtx := updateRequestContext(ctx, oldObj)
+			Expect(defaulter.Default(updateCtx, obj)).To(Succeed())
+			Expect(obj.Spec.Selector).To(BeNil())
+			_, err := validator.ValidateUpdate(updateCtx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("allowing such a set to adopt a selector")
+			obj.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "renamed"}}
+			_, err = validator.ValidateUpdate(updateCtx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("When updating only the metadata of MyStatefulSet", func() {
+		BeforeEach(func() {
+			By("simulating an object stored before the current validation rules existed")
+			oldObj.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests = nil
+			obj = oldObj.DeepCopy()
+		})
+
+		It("Should admit adding a finalizer", func() {
+			obj.Finalizers = []string{"apps.my.com/mystatefulset-cleanup"}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should admit removing the finalizer of a terminating object", func() {
+			now := metav1.Now()
+			oldObj.Finalizers = []string{"apps.my.com/mystatefulset-cleanup"}
+			oldObj.DeletionTimestamp = &now
+			obj = oldObj.DeepCopy()
+			obj.Finalizers = nil
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should still validate spec changes", func() {
+			replicas := int32(2)
+			obj.Spec.Replicas = &replicas
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("requests[storage]"))
+		})
+	})
+
 	Context("When creating or updating MyStatefulSet under Validating Webhook", func() {
 		It("Should deny creation if replicas is less than 1", func() {
 			By("simulating an invalid creation scenario with replicas less than 1")
@@ -79,6 +212,34 @@ var _ = Describe("MyStatefulSet Webhook", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("Should deny a negative start ordinal", func() {
+			replicas := int32(1)
+			obj.Spec.Replicas = &replicas
+			obj.Spec.Ordinals = &k8sappsv1.StatefulSetOrdinals{Start: -1}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.ordinals.start"))
+			_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+
+			obj.Spec.Ordinals.Start = 5
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should deny ordinals that overflow int32", func() {
+			replicas := int32(3)
+			obj.Spec.Replicas = &replicas
+			obj.Spec.Ordinals = &k8sappsv1.StatefulSetOrdinals{Start: math.MaxInt32 - 1}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("start + replicas - 1 must not exceed 2147483647"))
+
+			replicas = 2
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
 		It("Should validate updates correctly", func() {
 			By("simulating a valid update scenario with replicas set to 2")
 			oldReplicas := int32(1)
@@ -90,11 +251,285 @@ var _ = Describe("MyStatefulSet Webhook", func() {
 		})
 	})
 
+	Context("When updating MyStatefulSet under Validating Webhook", func() {
+		It("Should reject changes to immutable fields naming each field", func() {
+			obj = oldObj.DeepCopy()
+
+			By("admitting an update that only changes mutable fields")
+			newReplicas := int32(3)
+			obj.Spec.Replicas = &newReplicas
+			obj.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("treating an unset podManagementPolicy as OrderedReady")
+			obj.Spec.PodManagementPolicy = k8sappsv1.OrderedReadyPodManagement
+			_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("rejecting changes to every immutable field at once")
+			obj.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}
+			obj.Spec.Template.Labels = map[string]string{"app": "other"}
+			obj.Spec.ServiceName = "other"
+			obj.Spec.VolumeClaimTemplates[0].Name = "other"
+			obj.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			for _, path := range []string{"spec.selector", "spec.serviceName", "spec.volumeClaimTemplates", "spec.podManagementPolicy"} {
+				Expect(err.Error()).To(ContainSubstring(path))
+			}
+		})
+	})
+
+	Context("When validating the structure of a MyStatefulSet", func() {
+		It("Should admit a well-formed MyStatefulSet", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should report every structural problem as an aggregated field error", func() {
+			obj.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}
+			obj.Spec.ServiceName = "Not_A_DNS_Label"
+			obj.Spec.VolumeClaimTemplates = append(obj.Spec.VolumeClaimTemplates, corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data"},
+			})
+			obj.Spec.UpdateStrategy = k8sappsv1.StatefulSetUpdateStrategy{
+				Type:          k8sappsv1.OnDeleteStatefulSetStrategyType,
+				RollingUpdate: &k8sappsv1.RollingUpdateStatefulSetStrategy{},
+			}
+			obj.Spec.Template.Spec.Containers = nil
+
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			for _, path := range []string{
+				"spec.template.metadata.labels",
+				"spec.serviceName",
+				"spec.volumeClaimTemplates[1].metadata.name",
+				"spec.volumeClaimTemplates[1].spec.resources.requests[storage]",
+				"spec.updateStrategy.rollingUpdate",
+				"spec.template.spec.containers",
+			} {
+				Expect(err.Error()).To(ContainSubstring(path))
+			}
+		})
+
+		It("Should only admit a positive integer or percentage for maxUnavailable", func() {
+			for _, value := range []intstr.IntOrString{intstr.FromInt32(0), intstr.FromInt32(-1), intstr.FromString("0%"), intstr.FromString("101%"), intstr.FromString("two")} {
+				obj.Spec.UpdateStrategy = k8sappsv1.StatefulSetUpdateStrategy{
+					Type:          k8sappsv1.RollingUpdateStatefulSetStrategyType,
+					RollingUpdate: &k8sappsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: &value},
+				}
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(HaveOccurred(), "maxUnavailable %s", value.String())
+				Expect(err.Error()).To(ContainSubstring("spec.updateStrategy.rollingUpdate.maxUnavailable"))
+			}
+
+			for _, value := range []intstr.IntOrString{intstr.FromInt32(2), intstr.FromString("25%"), intstr.FromString("100%")} {
+				obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable = &value
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).ToNot(HaveOccurred(), "maxUnavailable %s", value.String())
+			}
+		})
+
+		It("Should require a non-empty selector", func() {
+			obj.Spec.Selector = &metav1.LabelSelector{}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.selector: Required value"))
+		})
+
+		It("Should keep pod and PVC names within 63 characters", func() {
+			obj.Name = strings.Repeat("a", 52)
+			obj.Spec.VolumeClaimTemplates[0].Name = "database"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("counting the highest ordinal in the PVC name")
+			replicas := int32(11)
+			obj.Spec.Replicas = &replicas
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).NotTo(ContainSubstring("metadata.name: Invalid value: \"" + obj.Name))
+			Expect(err.Error()).To(ContainSubstring("spec.volumeClaimTemplates[0].metadata.name"))
+
+			By("leaving room for the controller-revision-hash label value")
+			obj.Name = strings.Repeat("a", 53)
+			obj.Spec.VolumeClaimTemplates = nil
+			replicas = 1
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must be no more than 52 characters so that the controller-revision-hash label fits"))
+
+			By("rejecting set names that make pod names too long")
+			obj.Name = strings.Repeat("a", 62)
+			obj.Spec.VolumeClaimTemplates = nil
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("metadata.name"))
+		})
+	})
+
+	Context("When a MyStatefulSet is risky but legal", func() {
+		BeforeEach(func() {
+			obj.Spec.Template.Spec.Containers[0].ReadinessProbe = &corev1.Probe{}
+			obj.Spec.Template.Spec.Containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+			oldObj = obj.DeepCopy()
+		})
+
+		It("Should not warn about a well-configured MyStatefulSet", func() {
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("Should warn without denying the request", func() {
+			revisionHistoryLimit := int32(0)
+			obj.Spec.RevisionHistoryLimit = &revisionHistoryLimit
+			obj.Spec.Template.Spec.Containers[0].Image = "nginx"
+			obj.Spec.Template.Spec.Containers[0].ReadinessProbe = nil
+			obj.Spec.Template.Spec.Containers[0].Resources.Requests = nil
+			obj.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "registry.local:5000/busybox:latest"}}
+
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(
+				HavePrefix("spec.template.spec.initContainers[0].image: "),
+				HavePrefix("spec.template.spec.containers[0].image: "),
+				HavePrefix("spec.template.spec.containers[0].readinessProbe: "),
+				HavePrefix("spec.template.spec.containers[0].resources.requests: "),
+				HavePrefix("spec.revisionHistoryLimit: "),
+			))
+
+			By("not flagging images pinned to a tag or digest")
+			obj.Spec.Template.Spec.InitContainers[0].Image = "registry.local:5000/busybox@sha256:abc"
+			obj.Spec.Template.Spec.Containers[0].Image = "nginx:1.27"
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).NotTo(ContainElement(ContainSubstring("image")))
+		})
+
+		It("Should warn about Parallel pod management with volume claims", func() {
+			obj.Spec.PodManagementPolicy = k8sappsv1.ParallelPodManagement
+			obj.Spec.Template.Spec.Containers[0].ReadinessProbe = nil
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(HavePrefix("spec.podManagementPolicy: ")))
+		})
+
+		It("Should warn about scale-downs that remove PVC-backed ordinals", func() {
+			replicas := int32(5)
+			oldObj.Spec.Replicas = &replicas
+			warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("ordinals 1-4; their persistent volume claims are retained")))
+
+			obj.Spec.PersistentVolumeClaimRetentionPolicy = &k8sappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenScaled: k8sappsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+			}
+			warnings, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("their data will be lost")))
+		})
+	})
+
 	Context("When deleting MyStatefulSet under Validating Webhook", func() {
 		It("Should validate deletion correctly", func() {
 			By("simulating a deletion scenario")
 			_, err := validator.ValidateDelete(ctx, obj)
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("Should deny deleting a protected MyStatefulSet until the deletion is confirmed", func() {
+			obj.Annotations = map[string]string{deletionProtectionAnnotation: "true"}
+			_, err := validator.ValidateDelete(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(confirmDeletionAnnotation))
+
+			By("confirming with a different name")
+			obj.Annotations[confirmDeletionAnnotation] = "other"
+			_, err = validator.ValidateDelete(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+
+			By("confirming with the MyStatefulSet name")
+			obj.Annotations[confirmDeletionAnnotation] = obj.Name
+			_, err = validator.ValidateDelete(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should protect every MyStatefulSet under the cluster-wide policy", func() {
+			validator.ProtectAll = true
+			_, err := validator.ValidateDelete(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("Should report Ready pods when denying the deletion of a protected MyStatefulSet", func() {
+			obj.UID = "test-uid"
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "test-0",
+					Namespace:       obj.Namespace,
+					Labels:          map[string]string{"mystatefulset-name": obj.Name},
+					OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(obj, appsv1.GroupVersion.WithKind("MyStatefulSet"))},
+				},
+				Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				},
+			}
+			validator.Reader = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(pod).Build()
+
+			By("allowing the deletion of an unprotected MyStatefulSet")
+			_, err := validator.ValidateDelete(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+
+			obj.Annotations = map[string]string{deletionProtectionAnnotation: "true"}
+			_, err = validator.ValidateDelete(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("1 of its pods are still Ready"))
+
+			By("confirming the deletion")
+			obj.Annotations[confirmDeletionAnnotation] = obj.Name
+			_, err = validator.ValidateDelete(ctx, obj)
+			Expect(err).ToNot(HaveOccurred())
+		})
 	})
 })
+
+// updateRequestContext returns a context carrying an update admission request for the stored object
+func updateRequestContext(ctx context.Context, stored *appsv1.MyStatefulSet) context.Context {
+	raw, err := json.Marshal(stored)
+	Expect(err).ToNot(HaveOccurred())
+	return admission.NewContextWithRequest(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		OldObject: runtime.RawExtension{Raw: raw},
+	}})
+}
+
+// newValidMyStatefulSet returns a MyStatefulSet that passes structural validation
+func newValidMyStatefulSet() *appsv1.MyStatefulSet {
+	replicas := int32(1)
+	return &appsv1.MyStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: appsv1.MyStatefulSetSpec{
+			Replicas:    &replicas,
+			Selector:    &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+			ServiceName: "test",
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "nginx", Image: "nginx:1.27"}},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "data"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}},
+		},
+	}
+}